@@ -0,0 +1,83 @@
+package coding
+
+// bitWriter types write values bit by bit, most significant bit first.
+type bitWriter struct {
+
+	// The written bytes.
+	b []byte
+
+	// The number of unwritten bits in the last byte of b.
+	free int
+}
+
+// bitReader types read values bit by bit, most significant bit first.
+type bitReader struct {
+
+	// The bytes to read.
+	b []byte
+
+	// The offset of the next bit to read.
+	pos int
+}
+
+// Non-exported methods
+
+// writeBit writes a single bit.
+func (w *bitWriter) writeBit(bit bool) {
+	if bit {
+		w.writeBits(1, 1)
+	} else {
+		w.writeBits(0, 1)
+	}
+}
+
+// writeBits writes the n least significant bits of v.
+func (w *bitWriter) writeBits(v uint64, n int) {
+	for n > 0 {
+		if w.free == 0 {
+			w.b = append(w.b, 0)
+			w.free = 8
+		}
+
+		k := n
+		if k > w.free {
+			k = w.free
+		}
+
+		chunk := byte(v>>uint(n-k)) & byte(1<<uint(k)-1)
+		w.b[len(w.b)-1] |= chunk << uint(w.free-k)
+
+		w.free -= k
+		n -= k
+	}
+}
+
+// readBit reads a single bit.
+func (r *bitReader) readBit() (bool, error) {
+	v, err := r.readBits(1)
+	return v == 1, err
+}
+
+// readBits reads n bits in to the least significant bits of the returned
+// value.
+func (r *bitReader) readBits(n int) (uint64, error) {
+	if n > 8*len(r.b)-r.pos {
+		return 0, ErrEOB
+	}
+
+	var v uint64
+	for n > 0 {
+		avail := 8 - r.pos%8
+		k := n
+		if k > avail {
+			k = avail
+		}
+
+		chunk := (r.b[r.pos/8] >> uint(avail-k)) & byte(1<<uint(k)-1)
+		v = v<<uint(k) | uint64(chunk)
+
+		r.pos += k
+		n -= k
+	}
+	return v, nil
+}
@@ -0,0 +1,117 @@
+package coding
+
+import (
+	"math/bits"
+	"strconv"
+)
+
+// Bitset types are fixed-length sets of bits.
+type Bitset struct {
+
+	// The bitset's bits, least significant bit first.
+	bits []byte
+
+	// The number of bits in the bitset.
+	n int
+}
+
+// Initializers
+
+// NewBitset creates a new bitset with n cleared bits.
+func NewBitset(n int) *Bitset {
+	return &Bitset{
+		bits: make([]byte, (n+7)/8),
+		n:    n,
+	}
+}
+
+// NewBitsetFromBools creates a new bitset with the bits set that are true in
+// bs.
+func NewBitsetFromBools(bs []bool) *Bitset {
+	b := NewBitset(len(bs))
+	for i, v := range bs {
+		if v {
+			b.Set(i)
+		}
+	}
+	return b
+}
+
+// Exported methods
+
+// Len returns the number of bits in the bitset.
+func (b *Bitset) Len() int {
+	return b.n
+}
+
+// Set sets the i-th bit.
+//
+// Set panics if i is out of range.
+func (b *Bitset) Set(i int) {
+	b.checkIndex(i)
+	b.bits[i/8] |= 1 << uint(i%8)
+}
+
+// Clear clears the i-th bit.
+//
+// Clear panics if i is out of range.
+func (b *Bitset) Clear(i int) {
+	b.checkIndex(i)
+	b.bits[i/8] &^= 1 << uint(i%8)
+}
+
+// Test returns whether or not the i-th bit is set.
+//
+// Test panics if i is out of range.
+func (b *Bitset) Test(i int) bool {
+	b.checkIndex(i)
+	return b.bits[i/8]&(1<<uint(i%8)) != 0
+}
+
+// Count returns the number of set bits.
+func (b *Bitset) Count() int {
+	c := 0
+	for _, v := range b.bits {
+		c += bits.OnesCount8(v)
+	}
+	return c
+}
+
+// Bools returns the bitset's bits as booleans.
+func (b *Bitset) Bools() []bool {
+	bs := make([]bool, b.n)
+	for i := range bs {
+		bs[i] = b.Test(i)
+	}
+	return bs
+}
+
+// Non-exported methods
+
+// checkIndex panics if i isn't the index of one of the bitset's bits, so that
+// the bits past the end of the bitset stay cleared.
+func (b *Bitset) checkIndex(i int) {
+	if i < 0 || i >= b.n {
+		panic("coding: bitset index " + strconv.Itoa(i) + " out of range")
+	}
+}
+
+// runs returns the lengths of the bitset's alternating runs of cleared and set
+// bits, starting with a run of cleared bits that may be empty.
+func (b *Bitset) runs() []int {
+	var rs []int
+
+	l, v := 0, false
+	for i := 0; i < b.n; i++ {
+		if b.Test(i) != v {
+			rs = append(rs, l)
+			l, v = 0, !v
+		}
+		l++
+	}
+
+	if b.n > 0 {
+		rs = append(rs, l)
+	}
+	return rs
+}
@@ -23,4 +23,100 @@ const (
 	codingTypeString byte = 0x0D
 	codingTypeData   byte = 0x0E
 	codingTypeSlice  byte = 0x0F
+
+	codingTypeIndex   byte = 0x10
+	codingTypeSection byte = 0x11
+	codingTypeRecord  byte = 0x12
+
+	codingTypeNil byte = 0x13
+
+	codingTypeTime     byte = 0x14
+	codingTypeDuration byte = 0x15
+
+	codingTypeBigInt   byte = 0x16
+	codingTypeBigRat   byte = 0x17
+	codingTypeBigFloat byte = 0x18
+
+	codingTypeDecimal byte = 0x19
+
+	codingTypeComplex128 byte = 0x1A
+	codingTypeComplex64  byte = 0x1B
+
+	codingTypeInt128  byte = 0x1C
+	codingTypeUint128 byte = 0x1D
+
+	codingTypeFloat16       byte = 0x1E
+	codingTypeBFloat16      byte = 0x1F
+	codingTypeFloat16Slice  byte = 0x20
+	codingTypeBFloat16Slice byte = 0x21
+
+	codingTypeTensor byte = 0x22
+
+	codingTypeInt64Series   byte = 0x23
+	codingTypeFloat64Series byte = 0x24
+
+	codingTypeStringDef byte = 0x25
+	codingTypeStringRef byte = 0x26
+
+	codingTypeBitset byte = 0x27
+
+	codingTypeRunLengthSlice byte = 0x28
+
+	codingTypeSparseVector byte = 0x29
+
+	codingTypeAny    byte = 0x2A
+	codingTypeAnyDef byte = 0x2B
+	codingTypeAnyRef byte = 0x2C
+
+	codingTypeEnum byte = 0x2D
+
+	codingTypeUUID     byte = 0x2E
+	codingTypeAddr     byte = 0x2F
+	codingTypePrefix   byte = 0x30
+	codingTypeAddrPort byte = 0x31
+	codingTypeURL      byte = 0x32
+
+	codingTypeArray byte = 0x33
+
+	codingTypeSet byte = 0x34
+	codingTypeMap byte = 0x35
+)
+
+// A group of time zone kinds.
+const (
+	timeZoneUTC      byte = 0x00
+	timeZoneOffset   byte = 0x01
+	timeZoneLocation byte = 0x02
+)
+
+// A group of series encodings.
+const (
+	seriesDelta        byte = 0x00
+	seriesDeltaOfDelta byte = 0x01
+)
+
+// A group of bitset encodings.
+const (
+	bitsetPacked    byte = 0x00
+	bitsetRunLength byte = 0x01
 )
+
+// A group of enum encodings.
+const (
+	enumOrdinal byte = 0x00
+	enumName    byte = 0x01
+)
+
+// A group of map key orders.
+const (
+	mapInsertionOrder byte = 0x00
+	mapSorted         byte = 0x01
+)
+
+// defaultMaxElements is the default maximum number of elements that a decoder
+// expands a run-length encoded or sparse value to.
+const defaultMaxElements = 1 << 24
+
+// indexHeaderLength is the number of bytes in an index header; the index type
+// byte followed by the offset of the index table.
+const indexHeaderLength = 9
@@ -1,9 +1,19 @@
 package coding
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"math"
+	"math/big"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // Examples
@@ -123,6 +133,108 @@ func TestEncodeDecodeBool_2(t *testing.T) {
 	testEncodeDecode(i, t)
 }
 
+// Bitset
+
+func TestBitset(t *testing.T) {
+	b := NewBitset(10)
+	b.Set(0)
+	b.Set(9)
+	b.Set(4)
+	b.Clear(4)
+
+	if b.Len() != 10 || b.Count() != 2 {
+		t.Fatalf("Expected 2 of 10 bits set but received %d of %d.\n", b.Count(), b.Len())
+	}
+
+	if !b.Test(0) || b.Test(4) || !b.Test(9) {
+		t.Fatalf("Unexpected bits %v.\n", b.Bools())
+	}
+}
+
+func TestBitsetIndexRange(t *testing.T) {
+	b := NewBitset(3)
+	for _, f := range []func(){
+		func() { b.Set(5) },
+		func() { b.Set(3) },
+		func() { b.Clear(-1) },
+		func() { b.Test(3) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected an out of range index to panic.")
+				}
+			}()
+			f()
+		}()
+	}
+
+	e := NewEncoder()
+	e.EncodeBitset(b)
+
+	d := NewDecoder(e.Data())
+	if o, err := d.DecodeBitset(); err != nil || o.Len() != 3 || o.Count() != 0 {
+		t.Errorf("Expected an empty bitset of 3 bits but received %v, %v.\n", o, err)
+	}
+}
+
+func TestDecodeBitsetTooLarge(t *testing.T) {
+	e := NewEncoder()
+	e.appendType(codingTypeBitset)
+	e.appendUvarint(1 << 25)
+	e.appendByte(bitsetRunLength)
+	e.appendUvarint(1)
+	e.appendUvarint(1 << 25)
+
+	d := NewDecoder(e.Data())
+	if _, err := d.DecodeBitset(); err != ErrTooLarge {
+		t.Fatalf("Expected a value too large error but received: %v\n", err)
+	}
+
+	d = NewDecoder(e.Data())
+	d.SetMaxElements(1 << 25)
+	if b, err := d.DecodeBitset(); err != nil || b.Len() != 1<<25 || b.Count() != 0 {
+		t.Errorf("Expected an empty bitset of %d bits but received %v.\n", 1<<25, err)
+	}
+}
+
+func TestEncodeDecodeBools(t *testing.T) {
+	sparse := make([]bool, 10000)
+	sparse[17] = true
+	sparse[5000] = true
+	sparse[9999] = true
+
+	dense := make([]bool, 1001)
+	for i := range dense {
+		dense[i] = i%3 == 0
+	}
+
+	for _, i := range [][]bool{{}, {true}, {false, true, true}, sparse, dense} {
+		e := NewEncoder()
+		e.EncodeBools(i)
+
+		if l := len(e.data); l > len(i)/8+16 {
+			t.Fatalf("Expected at most %d bytes but received %d.\n", len(i)/8+16, l)
+		}
+
+		d := NewDecoder(e.Data())
+		o, err := d.DecodeBools()
+		if err != nil {
+			t.Fatalf("Error decoding type: %s\n", err)
+		}
+
+		if fmt.Sprint(o) != fmt.Sprint(i) {
+			t.Fatalf("Expected output %v to match input %v.\n", o, i)
+		}
+	}
+
+	e := NewEncoder()
+	e.EncodeBools(sparse)
+	if l := len(e.data); l > 16 {
+		t.Errorf("Expected at most 16 bytes but received %d.\n", l)
+	}
+}
+
 // Int
 
 func TestEncodeDecodeInt_1(t *testing.T) {
@@ -302,174 +414,3266 @@ func TestEncodeDecodeFloat32_3(t *testing.T) {
 	testEncodeDecode(i, t)
 }
 
-// String
+// Float16
+
+func TestFloat16Conversion(t *testing.T) {
+	cases := []struct {
+		f float32
+		h uint16
+	}{
+		{0, 0x0000},
+		{float32(math.Copysign(0, -1)), 0x8000},
+		{1, 0x3C00},
+		{-2, 0xC000},
+		{65504, 0x7BFF},
+		{65519, 0x7BFF},
+		{65520, 0x7C00},
+		{float32(math.Inf(-1)), 0xFC00},
+		{float32(math.Ldexp(1, -14)), 0x0400},
+		{float32(math.Ldexp(1, -24)), 0x0001},
+		{float32(math.Ldexp(1, -25)), 0x0000},
+		{float32(math.Ldexp(1.5, -25)), 0x0001},
+		{float32(math.Ldexp(3, -25)), 0x0002},
+		{1 + float32(math.Ldexp(1, -11)), 0x3C00},
+		{1 + float32(math.Ldexp(3, -11)), 0x3C02},
+	}
 
-func TestEncodeDecodeString_1(t *testing.T) {
-	var i string = ""
-	testEncodeDecode(i, t)
-}
+	for _, c := range cases {
+		if h := float32ToFloat16(c.f); h != c.h {
+			t.Errorf("Expected %g to convert to %#04x but received %#04x.\n", c.f, c.h, h)
+		}
+	}
 
-func TestEncodeDecodeString_2(t *testing.T) {
-	var i string = "Hello, World!"
-	testEncodeDecode(i, t)
+	if f := float16ToFloat32(float32ToFloat16(float32(math.NaN()))); !math.IsNaN(float64(f)) {
+		t.Errorf("Expected NaN but received %g.\n", f)
+	}
+
+	for h := 0; h <= math.MaxUint16; h++ {
+		f := float16ToFloat32(uint16(h))
+		if math.IsNaN(float64(f)) {
+			continue
+		}
+
+		if o := float32ToFloat16(f); o != uint16(h) {
+			t.Fatalf("Expected %#04x to round trip but received %#04x.\n", h, o)
+		}
+	}
 }
 
-// Data
+func TestBFloat16Conversion(t *testing.T) {
+	cases := []struct {
+		f float32
+		h uint16
+	}{
+		{0, 0x0000},
+		{1, 0x3F80},
+		{-2, 0xC000},
+		{math.MaxFloat32, 0x7F80},
+		{1 + float32(math.Ldexp(1, -8)), 0x3F80},
+		{1 + float32(math.Ldexp(3, -8)), 0x3F82},
+		{float32(math.Ldexp(1, -133)), 0x0001},
+	}
 
-func TestEncodeDecodeData_1(t *testing.T) {
-	var i []byte
-	testEncodeDecode(i, t)
+	for _, c := range cases {
+		if h := float32ToBFloat16(c.f); h != c.h {
+			t.Errorf("Expected %g to convert to %#04x but received %#04x.\n", c.f, c.h, h)
+		}
+	}
+
+	if f := bfloat16ToFloat32(float32ToBFloat16(float32(math.NaN()))); !math.IsNaN(float64(f)) {
+		t.Errorf("Expected NaN but received %g.\n", f)
+	}
+
+	for h := 0; h <= math.MaxUint16; h++ {
+		f := bfloat16ToFloat32(uint16(h))
+		if math.IsNaN(float64(f)) {
+			continue
+		}
+
+		if o := float32ToBFloat16(f); o != uint16(h) {
+			t.Fatalf("Expected %#04x to round trip but received %#04x.\n", h, o)
+		}
+	}
 }
 
-func TestEncodeDecodeData_2(t *testing.T) {
-	var i []byte = []byte{0x00, 0x01, 0x02, 0x03}
-	testEncodeDecode(i, t)
+func TestEncodeDecodeFloat16(t *testing.T) {
+	e := NewEncoder()
+	e.EncodeFloat16(1.5)
+	e.EncodeBFloat16(-3.25)
+
+	d := NewDecoder(e.Data())
+	if f, err := d.DecodeFloat16(); err != nil || f != 1.5 {
+		t.Fatalf("Expected output 1.5 but received %g, %v.\n", f, err)
+	}
+
+	if f, err := d.DecodeBFloat16(); err != nil || f != -3.25 {
+		t.Fatalf("Expected output -3.25 but received %g, %v.\n", f, err)
+	}
 }
 
-// CRC
+func TestEncodeDecodeFloat16s(t *testing.T) {
+	i := []float32{0, 1, -0.5, 65504, float32(math.Inf(1)), 0.099975586}
 
-func TestValidCRC_1(t *testing.T) {
 	e := NewEncoder()
-	e.EncodeString("")
+	e.EncodeFloat16s(i)
+	e.EncodeBFloat16s(i[:4])
+	e.EncodeFloat16s(nil)
 
 	d := NewDecoder(e.Data())
-	if err := d.Validate(); err != nil {
-		t.Errorf("CRC check failed: %s\n", err)
+	o, err := d.DecodeFloat16s()
+	if err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+
+	if len(o) != len(i) {
+		t.Fatalf("Data has unequal length %d != %d.\n", len(i), len(o))
+	}
+
+	for j, v := range i {
+		if v != o[j] {
+			t.Fatalf("Mismatched values %g and %g.\n", v, o[j])
+		}
+	}
+
+	o, err = d.DecodeBFloat16s()
+	if err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+
+	if len(o) != 4 || o[0] != 0 || o[1] != 1 || o[2] != -0.5 || o[3] != 65536 {
+		t.Fatalf("Unexpected output %v.\n", o)
+	}
+
+	if o, err = d.DecodeFloat16s(); err != nil || len(o) != 0 {
+		t.Fatalf("Expected no values but received %v, %v.\n", o, err)
 	}
 }
 
-func TestValidCRC_2(t *testing.T) {
+// Packed slice
+
+func TestEncodeDecodePackedSlices(t *testing.T) {
 	e := NewEncoder()
-	e.EncodeString("Hello, World!")
+	e.EncodeFloat64s([]float64{math.Pi, -math.MaxFloat64, math.Inf(1)})
+	e.EncodeFloat32s([]float32{math.E, -1})
+	e.EncodeInt64s([]int64{math.MinInt64, 0, math.MaxInt64})
+	e.EncodeInt32s([]int32{math.MinInt32, 1})
+	e.EncodeInt16s([]int16{math.MinInt16, math.MaxInt16})
+	e.EncodeInt8s([]int8{-1, 1})
+	e.EncodeUint64s([]uint64{math.MaxUint64})
+	e.EncodeUint32s(nil)
+	e.EncodeUint16s([]uint16{math.MaxUint16, 7})
+	e.EncodeUint8s([]uint8{0, 255})
 
 	d := NewDecoder(e.Data())
-	if err := d.Validate(); err != nil {
-		t.Errorf("CRC check failed: %s\n", err)
+	outputs := make([]interface{}, 10)
+	var err error
+	if outputs[0], err = d.DecodeFloat64s(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+	if outputs[1], err = d.DecodeFloat32s(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+	if outputs[2], err = d.DecodeInt64s(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+	if outputs[3], err = d.DecodeInt32s(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+	if outputs[4], err = d.DecodeInt16s(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+	if outputs[5], err = d.DecodeInt8s(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+	if outputs[6], err = d.DecodeUint64s(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+	if outputs[7], err = d.DecodeUint32s(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+	if outputs[8], err = d.DecodeUint16s(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+	if outputs[9], err = d.DecodeUint8s(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+
+	expected := "[[3.141592653589793 -1.7976931348623157e+308 +Inf] [2.7182817 -1] " +
+		"[-9223372036854775808 0 9223372036854775807] [-2147483648 1] [-32768 32767] [-1 1] " +
+		"[18446744073709551615] [] [65535 7] [0 255]]"
+	if o := fmt.Sprint(outputs); o != expected {
+		t.Errorf("Expected output %s to match %s.\n", o, expected)
 	}
 }
 
-func TestInalidCRC(t *testing.T) {
+func TestDecodeSliceInto(t *testing.T) {
+	i := []float64{1, 2, 3}
+
 	e := NewEncoder()
-	e.EncodeString("")
+	e.EncodeFloat64s(i)
 
-	d := NewDecoder(e.data)
-	if err := d.Validate(); err == nil {
-		t.Error("CRC check should have failed.\n", err)
+	d := NewDecoder(e.Data())
+	if _, err := d.DecodeFloat32s(); err != ErrType {
+		t.Fatalf("Expected a type mismatch error but received: %v\n", err)
+	}
+
+	if n, err := d.SliceLen(); err != nil || n != 3 {
+		t.Fatalf("Expected length 3 but received %d, %v.\n", n, err)
+	}
+
+	if _, err := d.DecodeSliceInto(make([]float64, 2)); err != ErrSliceLength {
+		t.Fatalf("Expected a slice too short error but received: %v\n", err)
+	}
+
+	o := make([]float64, 4)
+	n, err := d.DecodeSliceInto(o)
+	if err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+
+	if n != 3 || o[0] != 1 || o[1] != 2 || o[2] != 3 || o[3] != 0 {
+		t.Errorf("Unexpected output %v with length %d.\n", o, n)
 	}
 }
 
-// Compression
+func TestDecodeSliceIntoBigEndian(t *testing.T) {
+	// Exercise the byte-by-byte path that big endian hosts take
+	defer func(l bool) {
+		littleEndian = l
+	}(littleEndian)
+	littleEndian = false
 
-func TestCompressDecompress_1(t *testing.T) {
-	testCompressDecompress("", t)
+	e := NewEncoder()
+	e.EncodeInt32s([]int32{-1, 2, 3})
+	e.EncodeUint16s([]uint16{4})
+
+	d := NewDecoder(e.Data())
+	o := make([]int32, 8)
+	n, err := d.DecodeSliceInto(o)
+	if err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+
+	if n != 3 || fmt.Sprint(o) != "[-1 2 3 0 0 0 0 0]" {
+		t.Errorf("Unexpected output %v with length %d.\n", o, n)
+	}
+
+	if s, err := d.DecodeUint16s(); err != nil || len(s) != 1 || s[0] != 4 {
+		t.Errorf("Expected [4] but received %v, %v.\n", s, err)
+	}
 }
 
-func TestCompressDecompress_2(t *testing.T) {
-	testCompressDecompress("Hello, World!", t)
+func TestSliceLenIndexed(t *testing.T) {
+	e := NewEncoder()
+	e.SetIndexed(true)
+	e.EncodeFloat64s([]float64{1, 2, 3})
+
+	d := NewDecoder(e.Data())
+	if n, err := d.SliceLen(); err != nil || n != 3 {
+		t.Fatalf("Expected length 3 but received %d, %v.\n", n, err)
+	}
+
+	if s, err := d.DecodeFloat64s(); err != nil || fmt.Sprint(s) != "[1 2 3]" {
+		t.Errorf("Expected [1 2 3] but received %v, %v.\n", s, err)
+	}
 }
 
-// Non-exported functions
+func TestRunLengthSlices(t *testing.T) {
+	counters := make([]int64, 10000)
+	counters[5000] = 3
+	counters[5001] = 3
+
+	statuses := make([]uint16, 300)
+	for i := range statuses {
+		statuses[i] = uint16(200 + 100*(i/100))
+	}
 
-// testEncodeDecode attempts to encode the input value and then decode it.
-func testEncodeDecode(i interface{}, t *testing.T) {
 	e := NewEncoder()
+	e.EncodeInt64s(counters)
+	if l := len(e.data); l < 8*len(counters) {
+		t.Fatalf("Expected at least %d bytes but received %d.\n", 8*len(counters), l)
+	}
 
-	switch i.(type) {
-	case bool:
-		e.EncodeBool(i.(bool))
-	case int:
-		e.EncodeInt(i.(int))
-	case int64:
-		e.EncodeInt64(i.(int64))
-	case int32:
-		e.EncodeInt32(i.(int32))
-	case int16:
-		e.EncodeInt16(i.(int16))
-	case int8:
-		e.EncodeInt8(i.(int8))
-	case uint:
-		e.EncodeUint(i.(uint))
-	case uint64:
-		e.EncodeUint64(i.(uint64))
-	case uint32:
-		e.EncodeUint32(i.(uint32))
-	case uint16:
-		e.EncodeUint16(i.(uint16))
-	case uint8:
-		e.EncodeUint8(i.(uint8))
-	case float64:
-		e.EncodeFloat64(i.(float64))
-	case float32:
-		e.EncodeFloat32(i.(float32))
-	case string:
-		e.EncodeString(i.(string))
-	case []byte:
-		e.EncodeData(i.([]byte))
+	e.Flush()
+	e.SetRunLength(true)
+	e.EncodeInt64s(counters)
+	if l := len(e.data); l > 40 {
+		t.Fatalf("Expected at most 40 bytes but received %d.\n", l)
 	}
+	e.EncodeUint16s(statuses)
+	e.EncodeFloat64s([]float64{1, 1})
 
 	d := NewDecoder(e.Data())
+	if o, err := d.DecodeInt64s(); err != nil || fmt.Sprint(o) != fmt.Sprint(counters) {
+		t.Fatalf("Expected output to match input but received error %v.\n", err)
+	}
 
-	var o interface{}
-	var err error
+	if n, err := d.SliceLen(); err != nil || n != len(statuses) {
+		t.Fatalf("Expected length %d but received %d, %v.\n", len(statuses), n, err)
+	}
 
-	switch i.(type) {
-	case bool:
-		o, err = d.DecodeBool()
-	case int:
-		o, err = d.DecodeInt()
-	case int64:
-		o, err = d.DecodeInt64()
-	case int32:
-		o, err = d.DecodeInt32()
-	case int16:
-		o, err = d.DecodeInt16()
-	case int8:
-		o, err = d.DecodeInt8()
-	case uint:
-		o, err = d.DecodeUint()
-	case uint64:
-		o, err = d.DecodeUint64()
-	case uint32:
-		o, err = d.DecodeUint32()
-	case uint16:
-		o, err = d.DecodeUint16()
-	case uint8:
-		o, err = d.DecodeUint8()
-	case float64:
-		o, err = d.DecodeFloat64()
-	case float32:
-		o, err = d.DecodeFloat32()
-	case string:
-		o, err = d.DecodeString()
-	case []byte:
-		o, err = d.DecodeData()
+	if _, err := d.DecodeInt16s(); err != ErrType {
+		t.Fatalf("Expected a type mismatch error but received: %v\n", err)
 	}
 
-	if err != nil {
-		t.Fatalf("Error decoding type: %s\n", err)
+	o := make([]uint16, 400)
+	if n, err := d.DecodeSliceInto(o); err != nil || n != len(statuses) || fmt.Sprint(o[:n]) != fmt.Sprint(statuses) {
+		t.Fatalf("Expected output to match input but received %d, %v.\n", n, err)
 	}
 
-	switch i.(type) {
-	case []byte:
-		ib := i.([]byte)
-		ob := o.([]byte)
+	if f, err := d.DecodeFloat64s(); err != nil || len(f) != 2 || f[0] != 1 || f[1] != 1 {
+		t.Fatalf("Expected output [1 1] but received %v, %v.\n", f, err)
+	}
+}
 
-		if len(ib) == len(ob) {
-			for j, v := range ib {
-				if v != ob[j] {
-					t.Fatalf("Mismatched values %d and %d.\n", v, ob[j])
-				}
-			}
-		} else {
-			t.Fatalf("Data has unequal length %d != %d.\n", len(ib), len(ob))
-		}
-	default:
+func TestDecodeRunLengthSliceTooLarge(t *testing.T) {
+	e := NewEncoder()
+	e.appendType(codingTypeRunLengthSlice)
+	e.appendByte(codingTypeFloat64)
+	e.appendUvarint(1 << 40)
+	e.appendUvarint(1)
+	e.appendUvarint(1 << 40)
+	e.appendBytes(make([]byte, 8))
+
+	d := NewDecoder(e.Data())
+	if _, err := d.DecodeFloat64s(); err != ErrTooLarge {
+		t.Fatalf("Expected a value too large error but received: %v\n", err)
+	}
+
+	if _, err := d.SliceLen(); err != ErrTooLarge {
+		t.Fatalf("Expected a value too large error but received: %v\n", err)
+	}
+
+	e = NewEncoder()
+	e.SetRunLength(true)
+	e.EncodeUint8s(make([]uint8, 1000))
+
+	d = NewDecoder(e.Data())
+	d.SetMaxElements(999)
+	if _, err := d.DecodeUint8s(); err != ErrTooLarge {
+		t.Fatalf("Expected a value too large error but received: %v\n", err)
+	}
+
+	d.SetMaxElements(1000)
+	if s, err := d.DecodeUint8s(); err != nil || len(s) != 1000 {
+		t.Errorf("Expected 1000 elements but received %d, %v.\n", len(s), err)
+	}
+}
+
+func TestElementRuns(t *testing.T) {
+	if runs, ok := elementRuns([]byte{1, 1, 1, 1, 1, 2, 2, 2, 3}, 1); !ok || fmt.Sprint(runs) != "[5 3 1]" {
+		t.Errorf("Expected runs [5 3 1] but received %v, %t.\n", runs, ok)
+	}
+
+	if _, ok := elementRuns([]byte{1, 2, 3, 4}, 2); ok {
+		t.Error("Expected runs to be larger than the packed elements.")
+	}
+
+	if _, ok := elementRuns(nil, 8); ok {
+		t.Error("Expected no runs for an empty slice.")
+	}
+}
+
+func BenchmarkEncodeFloat64s(b *testing.B) {
+	s := make([]float64, 1000000)
+	for i := range s {
+		s[i] = float64(i)
+	}
+	e := NewEncoder()
+	b.SetBytes(int64(8 * len(s)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		e.Flush()
+		e.EncodeFloat64s(s)
+	}
+}
+
+func BenchmarkDecodeSliceInto(b *testing.B) {
+	s := make([]float64, 1000000)
+	for i := range s {
+		s[i] = float64(i)
+	}
+	e := NewEncoder()
+	e.EncodeFloat64s(s)
+	data := e.Data()
+	b.SetBytes(int64(8 * len(s)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		d := NewDecoder(data)
+		if _, err := d.DecodeSliceInto(s); err != nil {
+			b.Fatalf("Error decoding type: %s\n", err)
+		}
+	}
+}
+
+func BenchmarkCopy(b *testing.B) {
+	s := make([]byte, 8000000)
+	o := make([]byte, len(s))
+	b.SetBytes(int64(len(s)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		copy(o, s)
+	}
+}
+
+// Array
+
+func TestEncodeDecodeArray(t *testing.T) {
+	digest := sha256.Sum256([]byte("coding"))
+	ids := [3]int32{-1, 0, math.MaxInt32}
+
+	e := NewEncoder()
+	if err := e.EncodeArray(digest); err != nil {
+		t.Fatalf("Unable to encode array: %s\n", err)
+	}
+	if err := e.EncodeArray(&ids); err != nil {
+		t.Fatalf("Unable to encode array: %s\n", err)
+	}
+
+	d := NewDecoder(e.Data())
+	var od [32]byte
+	if err := d.DecodeArray(&od); err != nil || od != digest {
+		t.Errorf("Expected output %x but received %x, %v.\n", digest, od, err)
+	}
+
+	var oi [3]int32
+	if err := d.DecodeArray(&oi); err != nil || oi != ids {
+		t.Errorf("Expected output %v but received %v, %v.\n", ids, oi, err)
+	}
+}
+
+func TestEncodeDecodeArrayLength(t *testing.T) {
+	e := NewEncoder()
+	if err := e.EncodeArray([32]byte{}); err != nil {
+		t.Fatalf("Unable to encode array: %s\n", err)
+	}
+
+	// The array's length and kind replace the data's length prefix
+	if l := len(e.data); l != 35 {
+		t.Errorf("Expected 35 encoded bytes but received %d.\n", l)
+	}
+
+	d := NewDecoder(e.Data())
+	var short [20]byte
+	if err := d.DecodeArray(&short); err != ErrType {
+		t.Fatalf("Expected an incorrect type error but received: %v\n", err)
+	}
+
+	var wrongKind [32]int8
+	if err := d.DecodeArray(&wrongKind); err != ErrType {
+		t.Fatalf("Expected an incorrect type error but received: %v\n", err)
+	}
+
+	var digest [32]byte
+	if err := d.DecodeArray(&digest); err != nil {
+		t.Errorf("Error decoding array: %s\n", err)
+	}
+}
+
+func TestEncodeDecodeArrayUnsupported(t *testing.T) {
+	e := NewEncoder()
+	for _, a := range []interface{}{[]byte{1}, [2]string{}, [2]bool{}, 3} {
+		if err := e.EncodeArray(a); err != ErrType {
+			t.Errorf("Expected an incorrect type error for %T but received: %v\n", a, err)
+		}
+	}
+
+	d := NewDecoder(e.Data())
+	if err := d.DecodeArray([4]byte{}); err != ErrType {
+		t.Errorf("Expected an incorrect type error but received: %v\n", err)
+	}
+}
+
+// Sparse vector
+
+func TestEncodeDecodeSparseVector(t *testing.T) {
+	dense := make([]float32, 1024)
+	dense[0] = 1
+	dense[3] = -0.5
+	dense[1000] = math.MaxFloat32
+	dense[1023] = 2
+
+	e := NewEncoder()
+	e.EncodeSparseFloat32s(dense)
+	if l := len(e.data); l > 32 {
+		t.Fatalf("Expected at most 32 bytes but received %d.\n", l)
+	}
+	e.EncodeSparseFloat32s(dense)
+	e.EncodeSparseFloat64s([]float64{0, 0, 0})
+
+	d := NewDecoder(e.Data())
+	v, err := d.DecodeSparseVector()
+	if err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+
+	if v.Len() != len(dense) || fmt.Sprint(v.Indices()) != "[0 3 1000 1023]" {
+		t.Fatalf("Unexpected length %d and indices %v.\n", v.Len(), v.Indices())
+	}
+
+	if fmt.Sprint(v.Values()) != "[1 -0.5 3.4028235e+38 2]" {
+		t.Fatalf("Unexpected values %v.\n", v.Values())
+	}
+
+	o, err := d.DecodeSparseFloat64s()
+	if err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+
+	for i, f := range dense {
+		if float64(f) != o[i] {
+			t.Fatalf("Mismatched values %g and %g.\n", f, o[i])
+		}
+	}
+
+	if z, err := d.DecodeSparseFloat32s(); err != nil || len(z) != 3 || z[0] != 0 {
+		t.Fatalf("Expected three zeros but received %v, %v.\n", z, err)
+	}
+}
+
+func TestDecodeSparseVectorTooLarge(t *testing.T) {
+	e := NewEncoder()
+	e.EncodeSparseVector(&SparseVector{length: 1 << 40, values: []float64{}})
+
+	d := NewDecoder(e.Data())
+	if _, err := d.DecodeSparseFloat64s(); err != ErrTooLarge {
+		t.Fatalf("Expected a value too large error but received: %v\n", err)
+	}
+
+	d = NewDecoder(e.Data())
+	d.SetMaxElements(1 << 40)
+	if v, err := d.DecodeSparseVector(); err != nil || v.Len() != 1<<40 {
+		t.Errorf("Expected a sparse vector of length %d but received %v.\n", 1<<40, err)
+	}
+
+	e = NewEncoder()
+	e.EncodeSparseFloat64s(make([]float64, 1000))
+
+	d = NewDecoder(e.Data())
+	d.SetMaxElements(999)
+	if _, err := d.DecodeSparseFloat64s(); err != ErrTooLarge {
+		t.Errorf("Expected a value too large error but received: %v\n", err)
+	}
+}
+
+func TestNewSparseVector(t *testing.T) {
+	if _, err := NewSparseVector(10, []int{1, 9}, []float64{1, 2}); err != nil {
+		t.Fatalf("Unable to create sparse vector: %s\n", err)
+	}
+
+	invalid := []struct {
+		length  int
+		indices []int
+		values  interface{}
+	}{
+		{10, []int{2, 1}, []float64{1, 2}},
+		{10, []int{1, 1}, []float64{1, 2}},
+		{10, []int{10}, []float64{1}},
+		{10, []int{1}, []float64{1, 2}},
+		{10, []int{1}, []int64{1}},
+	}
+
+	for _, i := range invalid {
+		if _, err := NewSparseVector(i.length, i.indices, i.values); err != ErrSparseVector {
+			t.Errorf("Expected an invalid sparse vector error but received: %v\n", err)
+		}
+	}
+}
+
+// Series
+
+func TestEncodeDecodeInt64Series(t *testing.T) {
+	timestamps := make([]int64, 1000)
+	counters := make([]int64, 1000)
+	for i := range timestamps {
+		timestamps[i] = 1622548800000000000 + int64(i)*int64(time.Second)
+		if i%10 == 0 {
+			timestamps[i] += int64(i % 7)
+		}
+		counters[i] = int64(i * i % 97)
+	}
+
+	inputs := [][]int64{
+		{},
+		{42},
+		{math.MaxInt64, math.MinInt64, math.MaxInt64},
+		{5, 4, 3, 2, 1},
+		timestamps,
+		counters,
+	}
+
+	for _, i := range inputs {
+		e := NewEncoder()
+		e.EncodeInt64Series(i)
+
+		d := NewDecoder(e.Data())
+		o, err := d.DecodeInt64Series()
+		if err != nil {
+			t.Fatalf("Error decoding type: %s\n", err)
+		}
+
+		if fmt.Sprint(o) != fmt.Sprint(i) {
+			t.Fatalf("Expected output %v to match input %v.\n", o, i)
+		}
+	}
+}
+
+func TestInt64SeriesSize(t *testing.T) {
+	s := make([]int64, 1000)
+	for i := range s {
+		s[i] = 1622548800000000000 + int64(i)*int64(time.Second)
+	}
+
+	e := NewEncoder()
+	e.EncodeInt64Series(s)
+	if l := len(e.data); l > len(s)+32 {
+		t.Errorf("Expected at most %d bytes but received %d.\n", len(s)+32, l)
+	}
+}
+
+func TestEncodeDecodeFloat64Series(t *testing.T) {
+	readings := make([]float64, 1000)
+	for i := range readings {
+		readings[i] = 20 + math.Round(10*math.Sin(float64(i)/100))/4
+	}
+
+	inputs := [][]float64{
+		{},
+		{math.Pi},
+		{0, 0, 0, 1},
+		{math.Inf(1), math.Inf(-1), -0, math.MaxFloat64, math.SmallestNonzeroFloat64, 1, -1},
+		readings,
+	}
+
+	for _, i := range inputs {
+		e := NewEncoder()
+		e.EncodeFloat64Series(i)
+		e.EncodeBool(true)
+
+		d := NewDecoder(e.Data())
+		o, err := d.DecodeFloat64Series()
+		if err != nil {
+			t.Fatalf("Error decoding type: %s\n", err)
+		}
+
+		if len(o) != len(i) {
+			t.Fatalf("Data has unequal length %d != %d.\n", len(i), len(o))
+		}
+
+		for j, v := range i {
+			if math.Float64bits(v) != math.Float64bits(o[j]) {
+				t.Fatalf("Mismatched values %g and %g.\n", v, o[j])
+			}
+		}
+
+		if b, err := d.DecodeBool(); err != nil || !b {
+			t.Fatalf("Expected output true but received %t, %v.\n", b, err)
+		}
+	}
+
+	e := NewEncoder()
+	e.EncodeFloat64Series(readings)
+	if l := len(e.data); l > 2*len(readings) {
+		t.Errorf("Expected at most %d bytes but received %d.\n", 2*len(readings), l)
+	}
+}
+
+func TestBitWriterReader(t *testing.T) {
+	w := new(bitWriter)
+	w.writeBit(true)
+	w.writeBits(0x15, 5)
+	w.writeBits(math.MaxUint64, 64)
+	w.writeBits(0, 3)
+
+	r := &bitReader{b: w.b}
+	if b, err := r.readBit(); err != nil || !b {
+		t.Fatalf("Expected bit 1 but received %t, %v.\n", b, err)
+	}
+
+	if v, err := r.readBits(5); err != nil || v != 0x15 {
+		t.Fatalf("Expected bits 0x15 but received %#x, %v.\n", v, err)
+	}
+
+	if v, err := r.readBits(64); err != nil || v != math.MaxUint64 {
+		t.Fatalf("Expected bits %#x but received %#x, %v.\n", uint64(math.MaxUint64), v, err)
+	}
+
+	if v, err := r.readBits(3); err != nil || v != 0 {
+		t.Fatalf("Expected bits 0 but received %#x, %v.\n", v, err)
+	}
+
+	if _, err := r.readBits(8); err != ErrEOB {
+		t.Errorf("Expected end of buffer error but received: %v\n", err)
+	}
+}
+
+// Tensor
+
+func TestEncodeDecodeTensor(t *testing.T) {
+	inputs := []struct {
+		shape []int
+		data  interface{}
+	}{
+		{[]int{2, 3}, []float64{1, 2, 3, 4, 5, 6}},
+		{[]int{3, 1, 2}, []float32{-1, 0, 1, math.MaxFloat32, 0.5, -0.25}},
+		{[]int{}, []int64{math.MinInt64}},
+		{[]int{4}, []int8{-128, -1, 0, 127}},
+		{[]int{2, 0}, []uint16{}},
+		{[]int{2, 2}, []uint64{0, 1, math.MaxUint64, 42}},
+	}
+
+	for _, i := range inputs {
+		it, err := NewTensor(i.shape, i.data)
+		if err != nil {
+			t.Fatalf("Unable to create tensor: %s\n", err)
+		}
+
+		e := NewEncoder()
+		e.EncodeTensor(it)
+
+		d := NewDecoder(e.Data())
+		ot, err := d.DecodeTensor()
+		if err != nil {
+			t.Fatalf("Error decoding type: %s\n", err)
+		}
+
+		if fmt.Sprint(ot.Shape()) != fmt.Sprint(i.shape) {
+			t.Fatalf("Expected output shape %v to match input shape %v.\n", ot.Shape(), i.shape)
+		}
+
+		if fmt.Sprintf("%T%v", ot.Data(), ot.Data()) != fmt.Sprintf("%T%v", i.data, i.data) {
+			t.Fatalf("Expected output data %v to match input data %v.\n", ot.Data(), i.data)
+		}
+	}
+}
+
+func TestTensorSize(t *testing.T) {
+	data := make([]float64, 1000)
+	tt, err := NewTensor([]int{10, 100}, data)
+	if err != nil {
+		t.Fatalf("Unable to create tensor: %s\n", err)
+	}
+
+	e := NewEncoder()
+	e.EncodeTensor(tt)
+	if l := len(e.data); l > 8*len(data)+8 {
+		t.Errorf("Expected at most %d bytes but received %d.\n", 8*len(data)+8, l)
+	}
+}
+
+func TestNewTensorErrors(t *testing.T) {
+	if _, err := NewTensor([]int{2, 2}, []float64{1, 2, 3}); err != ErrTensor {
+		t.Errorf("Expected an invalid tensor error but received: %v\n", err)
+	}
+
+	if _, err := NewTensor([]int{-1}, []float64{}); err != ErrTensor {
+		t.Errorf("Expected an invalid tensor error but received: %v\n", err)
+	}
+
+	if _, err := NewTensor([]int{2}, []string{"a", "b"}); err != ErrTensor {
+		t.Errorf("Expected an invalid tensor error but received: %v\n", err)
+	}
+}
+
+// Complex128
+
+func TestEncodeDecodeComplex128_1(t *testing.T) {
+	var i complex128 = 0
+	testEncodeDecode(i, t)
+}
+
+func TestEncodeDecodeComplex128_2(t *testing.T) {
+	var i complex128 = complex(-math.Pi, math.MaxFloat64)
+	testEncodeDecode(i, t)
+}
+
+// Complex64
+
+func TestEncodeDecodeComplex64_1(t *testing.T) {
+	var i complex64 = 0
+	testEncodeDecode(i, t)
+}
+
+func TestEncodeDecodeComplex64_2(t *testing.T) {
+	var i complex64 = complex(math.Pi, -math.SmallestNonzeroFloat32)
+	testEncodeDecode(i, t)
+}
+
+// Int128
+
+func TestEncodeDecodeInt128_1(t *testing.T) {
+	testEncodeDecode(Int128{}, t)
+}
+
+func TestEncodeDecodeInt128_2(t *testing.T) {
+	testEncodeDecode(Int128{Hi: -1, Lo: math.MaxUint64}, t)
+}
+
+func TestEncodeDecodeInt128_3(t *testing.T) {
+	testEncodeDecode(Int128{Hi: math.MinInt64, Lo: 0}, t)
+}
+
+// Uint128
+
+func TestEncodeDecodeUint128_1(t *testing.T) {
+	testEncodeDecode(Uint128{}, t)
+}
+
+func TestEncodeDecodeUint128_2(t *testing.T) {
+	testEncodeDecode(Uint128{Hi: math.MaxUint64, Lo: math.MaxUint64}, t)
+}
+
+func TestDecodeUint128TypeMismatch(t *testing.T) {
+	e := NewEncoder()
+	e.EncodeInt128(Int128{Lo: 1})
+
+	d := NewDecoder(e.Data())
+	if _, err := d.DecodeUint128(); err != ErrType {
+		t.Errorf("Expected a type mismatch error but received: %v\n", err)
+	}
+}
+
+// String
+
+func TestEncodeDecodeString_1(t *testing.T) {
+	var i string = ""
+	testEncodeDecode(i, t)
+}
+
+func TestEncodeDecodeString_2(t *testing.T) {
+	var i string = "Hello, World!"
+	testEncodeDecode(i, t)
+}
+
+func TestEncodeDecodeRunes(t *testing.T) {
+	rs := []rune("héllo, 世界 🌍")
+
+	e := NewEncoder()
+	if err := e.EncodeRunes(rs); err != nil {
+		t.Fatalf("Unable to encode runes: %s\n", err)
+	}
+	if err := e.EncodeRunes([]rune{'a', 0xD800}); err != nil {
+		t.Fatalf("Unable to encode runes: %s\n", err)
+	}
+
+	d := NewDecoder(e.Data())
+	if o, err := d.DecodeRunes(); err != nil || string(o) != string(rs) {
+		t.Errorf("Expected output %q but received %q, %v.\n", string(rs), string(o), err)
+	}
+
+	if o, err := d.DecodeRunes(); err != nil || string(o) != "a\uFFFD" {
+		t.Errorf("Expected the invalid rune to be replaced but received %q, %v.\n", string(o), err)
+	}
+}
+
+func TestEncodeStrictUTF8(t *testing.T) {
+	e := NewEncoder()
+	e.SetStrictUTF8(true)
+
+	e.EncodeString("ok\xff")
+	if err := e.Err(); err != ErrUTF8 {
+		t.Fatalf("Expected an invalid UTF-8 error but received: %v\n", err)
+	}
+
+	e.EncodeString("ok")
+	if err := e.Err(); err != ErrUTF8 {
+		t.Fatalf("Expected the first error to be kept but received: %v\n", err)
+	}
+
+	if err := e.EncodeRunes([]rune{'a', 0x110000}); err != ErrUTF8 {
+		t.Fatalf("Expected an invalid UTF-8 error but received: %v\n", err)
+	}
+
+	// The data is missing the invalid string, so it isn't returned
+	if data := e.Data(); data != nil {
+		t.Errorf("Expected no data but received %v.\n", data)
+	}
+
+	if _, err := e.Compress(); err != ErrUTF8 {
+		t.Errorf("Expected an invalid UTF-8 error but received: %v\n", err)
+	}
+
+	e.Flush()
+	if err := e.Err(); err != nil {
+		t.Errorf("Expected flushing to clear the error but received: %v\n", err)
+	}
+
+	e.EncodeString("ok")
+	d := NewDecoder(e.Data())
+	if s, err := d.DecodeString(); err != nil || s != "ok" {
+		t.Errorf("Expected output ok but received %s, %v.\n", s, err)
+	}
+}
+
+func TestDecodeStrictUTF8(t *testing.T) {
+	for _, table := range []bool{false, true} {
+		e := NewEncoder()
+		e.SetStringTable(table)
+		e.EncodeString("ok\xff")
+		e.EncodeString("ok")
+
+		d := NewDecoder(e.Data())
+		d.SetStrictUTF8(true)
+		if _, err := d.DecodeString(); err != ErrUTF8 {
+			t.Fatalf("Expected an invalid UTF-8 error but received: %v\n", err)
+		}
+
+		if s, err := d.DecodeString(); err != nil || s != "ok" {
+			t.Errorf("Expected output ok but received %s, %v.\n", s, err)
+		}
+	}
+}
+
+func TestEncodeNormalizer(t *testing.T) {
+	// Composes e followed by a combining acute accent, as NFC would
+	nfc := func(s string) string {
+		return strings.ReplaceAll(s, "e\u0301", "\u00e9")
+	}
+
+	e := NewEncoder()
+	e.SetNormalizer(nfc)
+	e.EncodeString("cafe\u0301")
+	_ = e.EncodeRunes([]rune("re\u0301sume\u0301"))
+
+	d := NewDecoder(e.Data())
+	if s, err := d.DecodeString(); err != nil || s != "caf\u00e9" {
+		t.Errorf("Expected output %q but received %q, %v.\n", "caf\u00e9", s, err)
+	}
+
+	if rs, err := d.DecodeRunes(); err != nil || len(rs) != 6 {
+		t.Errorf("Expected 6 normalized runes but received %q, %v.\n", string(rs), err)
+	}
+}
+
+// String table
+
+func TestStringTable(t *testing.T) {
+	values := []string{"US", "active", "US", "", "DE", "active", "", "US"}
+
+	e := NewEncoder()
+	e.SetStringTable(true)
+	for _, v := range values {
+		e.EncodeString(v)
+	}
+
+	p := NewEncoder()
+	for _, v := range values {
+		p.EncodeString(v)
+	}
+
+	if len(e.data) >= len(p.data) {
+		t.Errorf("Expected fewer than %d bytes but received %d.\n", len(p.data), len(e.data))
+	}
+
+	d := NewDecoder(e.Data())
+	for _, v := range values {
+		if s, err := d.DecodeString(); err != nil || s != v {
+			t.Fatalf("Expected output %s but received %s, %v.\n", v, s, err)
+		}
+	}
+}
+
+func TestStringTableSection(t *testing.T) {
+	e := NewEncoder()
+	e.SetStringTable(true)
+	e.EncodeString("a")
+	e.BeginSection()
+	e.EncodeString("a")
+	e.EncodeString("b")
+	if err := e.EndSection(); err != nil {
+		t.Fatalf("Unable to end section: %s\n", err)
+	}
+	e.EncodeString("b")
+
+	d := NewDecoder(e.Data())
+	if s, err := d.DecodeString(); err != nil || s != "a" {
+		t.Fatalf("Expected output a but received %s, %v.\n", s, err)
+	}
+
+	sd, err := d.EnterSection()
+	if err != nil {
+		t.Fatalf("Unable to enter section: %s\n", err)
+	}
+
+	for _, v := range []string{"a", "b"} {
+		if s, err := sd.DecodeString(); err != nil || s != v {
+			t.Fatalf("Expected output %s but received %s, %v.\n", v, s, err)
+		}
+	}
+
+	if s, err := d.DecodeString(); err != nil || s != "b" {
+		t.Fatalf("Expected output b but received %s, %v.\n", s, err)
+	}
+
+	d = NewDecoder(e.Data())
+	if _, err := d.DecodeString(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+
+	if err := d.SkipSection(); err != nil {
+		t.Fatalf("Unable to skip section: %s\n", err)
+	}
+
+	if s, err := d.DecodeString(); err != nil || s != "b" {
+		t.Errorf("Expected output b after skipping its definition but received %s, %v.\n", s, err)
+	}
+}
+
+func TestStringTableParallelSections(t *testing.T) {
+	e := NewEncoder()
+	e.SetStringTable(true)
+	e.EncodeString("shared")
+	for i := 0; i < 8; i++ {
+		e.BeginSection()
+		for j := 0; j < 16; j++ {
+			e.EncodeString("shared")
+			e.EncodeString(fmt.Sprint(i, j))
+			e.EncodeString(fmt.Sprint(i, j))
+		}
+		_ = e.EndSection()
+	}
+
+	d := NewDecoder(e.Data())
+	if _, err := d.DecodeString(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+
+	var sds []*Decoder
+	for i := 0; i < 8; i++ {
+		sd, err := d.EnterSection()
+		if err != nil {
+			t.Fatalf("Unable to enter section: %s\n", err)
+		}
+		sds = append(sds, sd)
+	}
+
+	// Run with -race to check the sections' shared string table
+	var wg sync.WaitGroup
+	errs := make([]error, len(sds))
+	for i, sd := range sds {
+		wg.Add(1)
+		go func(i int, sd *Decoder) {
+			defer wg.Done()
+			for j := 0; j < 16; j++ {
+				for _, v := range []string{"shared", fmt.Sprint(i, j), fmt.Sprint(i, j)} {
+					if s, err := sd.DecodeString(); err != nil || s != v {
+						errs[i] = fmt.Errorf("expected %s but received %s, %v", v, s, err)
+						return
+					}
+				}
+			}
+		}(i, sd)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Error decoding section %d: %s\n", i, err)
+		}
+	}
+}
+
+func TestStringTableRecord(t *testing.T) {
+	e := NewEncoder()
+	e.SetStringTable(true)
+	e.BeginRecord()
+	mustField(e, 1, t)
+	e.EncodeString("x")
+	if err := e.EndRecord(); err != nil {
+		t.Fatalf("Unable to end record: %s\n", err)
+	}
+	e.EncodeString("y")
+	e.EncodeString("y")
+
+	d := NewDecoder(e.Data())
+	r, err := d.DecodeRecord()
+	if err != nil {
+		t.Fatalf("Unable to decode record: %s\n", err)
+	}
+
+	// Decoding a field twice must not change what later references refer to
+	for i := 0; i < 2; i++ {
+		fd, err := r.Field(1)
+		if err != nil {
+			t.Fatalf("Unable to get field: %s\n", err)
+		}
+
+		if s, err := fd.DecodeString(); err != nil || s != "x" {
+			t.Fatalf("Expected output x but received %s, %v.\n", s, err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if s, err := d.DecodeString(); err != nil || s != "y" {
+			t.Errorf("Expected output y but received %s, %v.\n", s, err)
+		}
+	}
+}
+
+func TestStringTableIndexed(t *testing.T) {
+	e := NewEncoder()
+	e.SetIndexed(true)
+	e.SetStringTable(true)
+	e.EncodeString("a")
+	e.EncodeString("b")
+	e.EncodeString("a")
+	e.EncodeString("b")
+
+	d := NewDecoder(e.Data())
+	ad, err := d.At(3)
+	if err != nil {
+		t.Fatalf("Unable to get decoder: %s\n", err)
+	}
+
+	if s, err := ad.DecodeString(); err != nil || s != "b" {
+		t.Errorf("Expected output b but received %s, %v.\n", s, err)
+	}
+
+	if err := d.Seek(2); err != nil {
+		t.Fatalf("Unable to seek: %s\n", err)
+	}
+
+	if s, err := d.DecodeString(); err != nil || s != "a" {
+		t.Errorf("Expected output a but received %s, %v.\n", s, err)
+	}
+}
+
+func TestStringTableInvalidReference(t *testing.T) {
+	for _, r := range [][]byte{{0}, {3}, {2}} {
+		e := NewEncoder()
+		e.EncodeInt8(1)
+		e.appendType(codingTypeStringRef)
+		e.appendBytes(r)
+
+		d := NewDecoder(e.Data())
+		if _, err := d.DecodeInt8(); err != nil {
+			t.Fatalf("Error decoding type: %s\n", err)
+		}
+
+		if _, err := d.DecodeString(); err != ErrStringRef {
+			t.Errorf("Expected an unknown string reference error for %v but received: %v\n", r, err)
+		}
+	}
+}
+
+func TestStringTableFlush(t *testing.T) {
+	e := NewEncoder()
+	e.SetStringTable(true)
+	e.EncodeString("a")
+	e.Flush()
+	e.EncodeString("a")
+
+	d := NewDecoder(e.Data())
+	if s, err := d.DecodeString(); err != nil || s != "a" {
+		t.Errorf("Expected output a but received %s, %v.\n", s, err)
+	}
+}
+
+// Data
+
+func TestEncodeDecodeData_1(t *testing.T) {
+	var i []byte
+	testEncodeDecode(i, t)
+}
+
+func TestEncodeDecodeData_2(t *testing.T) {
+	var i []byte = []byte{0x00, 0x01, 0x02, 0x03}
+	testEncodeDecode(i, t)
+}
+
+// Big
+
+func TestEncodeDecodeBigInt(t *testing.T) {
+	x, _ := new(big.Int).SetString("-123456789012345678901234567890123456789", 10)
+	for _, i := range []*big.Int{big.NewInt(0), big.NewInt(math.MaxInt64), x} {
+		e := NewEncoder()
+		e.EncodeBigInt(i)
+
+		d := NewDecoder(e.Data())
+		o, err := d.DecodeBigInt()
+		if err != nil {
+			t.Fatalf("Error decoding type: %s\n", err)
+		}
+
+		if o.Cmp(i) != 0 {
+			t.Fatalf("Expected output %s to match input %s.\n", o, i)
+		}
+	}
+}
+
+func TestEncodeDecodeBigRat(t *testing.T) {
+	x, _ := new(big.Rat).SetString("-340282366920938463463374607431768211457/3")
+	for _, i := range []*big.Rat{new(big.Rat), big.NewRat(1, 3), x} {
+		e := NewEncoder()
+		e.EncodeBigRat(i)
+
+		d := NewDecoder(e.Data())
+		o, err := d.DecodeBigRat()
+		if err != nil {
+			t.Fatalf("Error decoding type: %s\n", err)
+		}
+
+		if o.Cmp(i) != 0 {
+			t.Fatalf("Expected output %s to match input %s.\n", o, i)
+		}
+	}
+}
+
+func TestEncodeDecodeBigFloat(t *testing.T) {
+	x, _, _ := big.ParseFloat("-1.5e1000", 10, 200, big.ToZero)
+	inputs := []*big.Float{
+		new(big.Float),
+		big.NewFloat(math.Pi),
+		new(big.Float).SetInf(true),
+		new(big.Float).SetPrec(1000).SetMode(big.AwayFromZero).SetFloat64(math.E),
+		x,
+	}
+
+	for _, i := range inputs {
+		e := NewEncoder()
+		e.EncodeBigFloat(i)
+
+		d := NewDecoder(e.Data())
+		o, err := d.DecodeBigFloat()
+		if err != nil {
+			t.Fatalf("Error decoding type: %s\n", err)
+		}
+
+		if o.Cmp(i) != 0 || o.Prec() != i.Prec() || o.Mode() != i.Mode() {
+			t.Fatalf("Expected output %s (%d, %s) to match input %s (%d, %s).\n", o, o.Prec(), o.Mode(), i, i.Prec(), i.Mode())
+		}
+	}
+}
+
+func TestDecodeBigTypeMismatch(t *testing.T) {
+	e := NewEncoder()
+	e.EncodeBigInt(big.NewInt(1))
+
+	d := NewDecoder(e.Data())
+	if _, err := d.DecodeBigRat(); err != ErrType {
+		t.Errorf("Expected a type mismatch error but received: %v\n", err)
+	}
+}
+
+// Decimal
+
+func TestEncodeDecodeDecimal_1(t *testing.T) {
+	testEncodeDecode(NewDecimal(0, 0), t)
+}
+
+func TestEncodeDecodeDecimal_2(t *testing.T) {
+	testEncodeDecode(NewDecimal(123450, 4), t)
+}
+
+func TestEncodeDecodeDecimal_3(t *testing.T) {
+	testEncodeDecode(NewDecimal(math.MinInt64, 18), t)
+}
+
+func TestParseDecimal(t *testing.T) {
+	for _, s := range []string{"0", "12.3450", "-0.05", "100", "0.000", "9223372036854775807", "-922337203685477.5808"} {
+		x, err := ParseDecimal(s)
+		if err != nil {
+			t.Fatalf("Unable to parse decimal %s: %s\n", s, err)
+		}
+
+		if x.String() != s {
+			t.Fatalf("Expected output %s to match input %s.\n", x, s)
+		}
+	}
+
+	for _, s := range []string{"", "-", ".", "1.2.3", "1e5", "abc", "99999999999999999999"} {
+		if _, err := ParseDecimal(s); err != ErrDecimal {
+			t.Fatalf("Expected an invalid decimal error for %q but received: %v\n", s, err)
+		}
+	}
+
+	if x, _ := ParseDecimal("+1.50"); x.Unscaled() != 150 || x.Scale() != 2 {
+		t.Errorf("Expected 150 and 2 but received %d and %d.\n", x.Unscaled(), x.Scale())
+	}
+}
+
+// Time
+
+func TestEncodeDecodeTime_1(t *testing.T) {
+	testEncodeDecodeTime(time.Time{}, t)
+}
+
+func TestEncodeDecodeTime_2(t *testing.T) {
+	testEncodeDecodeTime(time.Date(2021, 6, 1, 12, 30, 15, 123456789, time.UTC), t)
+}
+
+func TestEncodeDecodeTime_3(t *testing.T) {
+	testEncodeDecodeTime(time.Date(9999, 12, 31, 23, 59, 59, 999999999, time.FixedZone("XST", -7*3600)), t)
+}
+
+func TestEncodeDecodeTime_4(t *testing.T) {
+	testEncodeDecodeTime(time.Date(-4713, 1, 1, 0, 0, 0, 1, time.FixedZone("", 5*3600+1800)), t)
+}
+
+func TestEncodeDecodeTime_5(t *testing.T) {
+	l, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Unable to load location: %s\n", err)
+	}
+	testEncodeDecodeTime(time.Date(2021, 3, 14, 1, 59, 59, 0, l), t)
+}
+
+func TestEncodeDecodeTime_6(t *testing.T) {
+	testEncodeDecodeTime(time.Now(), t)
+}
+
+// Duration
+
+func TestEncodeDecodeDuration_1(t *testing.T) {
+	var i time.Duration = 0
+	testEncodeDecode(i, t)
+}
+
+func TestEncodeDecodeDuration_2(t *testing.T) {
+	var i time.Duration = -time.Hour - time.Nanosecond
+	testEncodeDecode(i, t)
+}
+
+func TestEncodeDecodeDuration_3(t *testing.T) {
+	var i time.Duration = math.MaxInt64
+	testEncodeDecode(i, t)
+}
+
+// UUID
+
+func TestEncodeDecodeUUID(t *testing.T) {
+	u, err := ParseUUID("123e4567-e89b-12d3-a456-426614174000")
+	if err != nil {
+		t.Fatalf("Unable to parse UUID: %s\n", err)
+	}
+
+	if u.String() != "123e4567-e89b-12d3-a456-426614174000" {
+		t.Fatalf("Expected UUID to format as its input but received %s.\n", u)
+	}
+
+	e := NewEncoder()
+	e.EncodeUUID(u)
+	e.EncodeUUID(UUID{})
+
+	d := NewDecoder(e.Data())
+	if o, err := d.DecodeUUID(); err != nil || o != u {
+		t.Errorf("Expected output %s but received %s, %v.\n", u, o, err)
+	}
+	if o, err := d.DecodeUUID(); err != nil || o != (UUID{}) {
+		t.Errorf("Expected the nil UUID but received %s, %v.\n", o, err)
+	}
+}
+
+func TestParseUUIDErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"123e4567e89b12d3a456426614174000",
+		"123e4567-e89b-12d3-a456-42661417400g",
+		"123e4567-e89b-12d3-a456_426614174000",
+	} {
+		if _, err := ParseUUID(s); err != ErrUUID {
+			t.Errorf("Expected an invalid UUID error for %q but received: %v\n", s, err)
+		}
+	}
+}
+
+// Network
+
+func TestEncodeDecodeAddr(t *testing.T) {
+	inputs := []netip.Addr{
+		netip.MustParseAddr("192.168.1.1"),
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("fe80::1%eth0"),
+		netip.MustParseAddr("::ffff:10.0.0.1"),
+		{},
+	}
+
+	e := NewEncoder()
+	for _, a := range inputs {
+		e.EncodeAddr(a)
+	}
+
+	d := NewDecoder(e.Data())
+	for _, a := range inputs {
+		if o, err := d.DecodeAddr(); err != nil || o != a {
+			t.Errorf("Expected output %v but received %v, %v.\n", a, o, err)
+		}
+	}
+}
+
+func TestEncodeDecodePrefix(t *testing.T) {
+	inputs := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("192.168.1.7/24"),
+		{},
+	}
+
+	e := NewEncoder()
+	for _, p := range inputs {
+		e.EncodePrefix(p)
+	}
+
+	d := NewDecoder(e.Data())
+	for _, p := range inputs {
+		if o, err := d.DecodePrefix(); err != nil || o != p {
+			t.Errorf("Expected output %v but received %v, %v.\n", p, o, err)
+		}
+	}
+}
+
+func TestDecodePrefixInvalid(t *testing.T) {
+	e := NewEncoder()
+	e.EncodePrefix(netip.MustParsePrefix("10.0.0.0/8"))
+
+	// Give the IPv4 prefix 33 bits
+	e.data[len(e.data)-1] = 66
+
+	d := NewDecoder(e.Data())
+	if _, err := d.DecodePrefix(); err != ErrValue {
+		t.Errorf("Expected an invalid value error but received: %v\n", err)
+	}
+}
+
+func TestEncodeDecodeAddrPort(t *testing.T) {
+	inputs := []netip.AddrPort{
+		netip.MustParseAddrPort("127.0.0.1:8080"),
+		netip.MustParseAddrPort("[::1]:443"),
+		{},
+	}
+
+	e := NewEncoder()
+	for _, ap := range inputs {
+		e.EncodeAddrPort(ap)
+	}
+
+	d := NewDecoder(e.Data())
+	for _, ap := range inputs {
+		if o, err := d.DecodeAddrPort(); err != nil || o != ap {
+			t.Errorf("Expected output %v but received %v, %v.\n", ap, o, err)
+		}
+	}
+}
+
+func TestDecodeAddrInvalid(t *testing.T) {
+	e := NewEncoder()
+	e.EncodeAddr(netip.MustParseAddr("192.168.1.1"))
+
+	// Give the address five bytes
+	e.data[1] = 5
+
+	d := NewDecoder(e.Data())
+	if _, err := d.DecodeAddr(); err != ErrValue {
+		t.Errorf("Expected an invalid value error but received: %v\n", err)
+	}
+}
+
+func TestEncodeDecodeURL(t *testing.T) {
+	u, err := url.Parse("https://user@example.com:8443/a/b?q=1#top")
+	if err != nil {
+		t.Fatalf("Unable to parse URL: %s\n", err)
+	}
+
+	e := NewEncoder()
+	e.EncodeURL(u)
+	e.EncodeURL(nil)
+	e.appendType(codingTypeURL)
+	e.appendString("http://[::1")
+
+	d := NewDecoder(e.Data())
+	if o, err := d.DecodeURL(); err != nil || o.String() != u.String() {
+		t.Errorf("Expected output %v but received %v, %v.\n", u, o, err)
+	}
+
+	if o, err := d.DecodeURL(); err != nil || o != nil {
+		t.Errorf("Expected a nil URL but received %v, %v.\n", o, err)
+	}
+
+	if _, err := d.DecodeURL(); err != ErrValue {
+		t.Errorf("Expected an invalid value error but received: %v\n", err)
+	}
+}
+
+// Nil
+
+func TestDecodeNil(t *testing.T) {
+	e := NewEncoder()
+	e.EncodeNil()
+	e.EncodeInt(1)
+
+	d := NewDecoder(e.Data())
+	if isNil, err := d.DecodeNil(); err != nil || !isNil {
+		t.Fatalf("Expected nil but received %t, %v.\n", isNil, err)
+	}
+
+	if isNil, err := d.DecodeNil(); err != nil || isNil {
+		t.Fatalf("Expected a non-nil value but received %t, %v.\n", isNil, err)
+	}
+
+	if n, err := d.DecodeInt(); err != nil || n != 1 {
+		t.Errorf("Expected output 1 but received %d, %v.\n", n, err)
+	}
+}
+
+func TestDecodeNullable(t *testing.T) {
+	e := NewEncoder()
+	e.EncodeNil()
+	e.EncodeInt64(-5)
+	e.EncodeNil()
+	e.EncodeString("")
+	e.EncodeNil()
+	e.EncodeData(nil)
+
+	d := NewDecoder(e.Data())
+	if p, err := d.DecodeNullableInt64(); err != nil || p != nil {
+		t.Fatalf("Expected nil but received %v, %v.\n", p, err)
+	}
+
+	if p, err := d.DecodeNullableInt64(); err != nil || p == nil || *p != -5 {
+		t.Fatalf("Expected output -5 but received %v, %v.\n", p, err)
+	}
+
+	if p, err := d.DecodeNullableString(); err != nil || p != nil {
+		t.Fatalf("Expected nil but received %v, %v.\n", p, err)
+	}
+
+	if p, err := d.DecodeNullableString(); err != nil || p == nil || *p != "" {
+		t.Fatalf("Expected an empty string but received %v, %v.\n", p, err)
+	}
+
+	if b, err := d.DecodeNullableData(); err != nil || b != nil {
+		t.Fatalf("Expected nil but received %v, %v.\n", b, err)
+	}
+
+	if b, err := d.DecodeNullableData(); err != nil || b == nil || len(b) != 0 {
+		t.Fatalf("Expected empty data but received %v, %v.\n", b, err)
+	}
+}
+
+func TestDecodeNullableTypeMismatch(t *testing.T) {
+	e := NewEncoder()
+	e.EncodeBool(true)
+
+	d := NewDecoder(e.Data())
+	if _, err := d.DecodeNullableInt(); err != ErrType {
+		t.Errorf("Expected a type mismatch error but received: %v\n", err)
+	}
+}
+
+func TestEncodeNilPointers(t *testing.T) {
+	e := NewEncoder()
+	e.EncodeBigInt(nil)
+	e.EncodeBigRat(nil)
+	e.EncodeBigFloat(nil)
+	e.EncodeTensor(nil)
+	e.EncodeBitset(nil)
+	e.EncodeSparseVector(nil)
+
+	d := NewDecoder(e.Data())
+	for i := 0; i < 6; i++ {
+		if isNil, err := d.DecodeNil(); err != nil || !isNil {
+			t.Errorf("Expected nil value %d but received %t, %v.\n", i, isNil, err)
+		}
+	}
+}
+
+// CRC
+
+func TestValidCRC_1(t *testing.T) {
+	e := NewEncoder()
+	e.EncodeString("")
+
+	d := NewDecoder(e.Data())
+	if err := d.Validate(); err != nil {
+		t.Errorf("CRC check failed: %s\n", err)
+	}
+}
+
+func TestValidCRC_2(t *testing.T) {
+	e := NewEncoder()
+	e.EncodeString("Hello, World!")
+
+	d := NewDecoder(e.Data())
+	if err := d.Validate(); err != nil {
+		t.Errorf("CRC check failed: %s\n", err)
+	}
+}
+
+func TestInalidCRC(t *testing.T) {
+	e := NewEncoder()
+	e.EncodeString("")
+
+	d := NewDecoder(e.data)
+	if err := d.Validate(); err == nil {
+		t.Error("CRC check should have failed.\n", err)
+	}
+}
+
+// Compression
+
+func TestCompressDecompress_1(t *testing.T) {
+	testCompressDecompress("", t)
+}
+
+func TestCompressDecompress_2(t *testing.T) {
+	testCompressDecompress("Hello, World!", t)
+}
+
+// Index
+
+func TestIndexSeek(t *testing.T) {
+	e := NewEncoder()
+	e.SetIndexed(true)
+	for i := 0; i < 100; i++ {
+		e.EncodeInt(i)
+	}
+
+	d := NewDecoder(e.Data())
+	if err := d.Validate(); err != nil {
+		t.Fatalf("CRC check failed: %s\n", err)
+	}
+
+	if n, err := d.IndexLen(); err != nil {
+		t.Fatalf("Unable to get index length: %s\n", err)
+	} else if n != 100 {
+		t.Fatalf("Expected 100 indexed values but found %d.\n", n)
+	}
+
+	for _, i := range []int{50, 0, 99, 42} {
+		if err := d.Seek(i); err != nil {
+			t.Fatalf("Unable to seek to value %d: %s\n", i, err)
+		}
+
+		if n, err := d.DecodeInt(); err != nil {
+			t.Fatalf("Error decoding type: %s\n", err)
+		} else if n != i {
+			t.Fatalf("Expected output %d to match input %d.\n", n, i)
+		}
+	}
+
+	if err := d.Seek(100); err != ErrIndexRange {
+		t.Errorf("Expected an index out of range error but received: %v\n", err)
+	}
+}
+
+func TestIndexAt(t *testing.T) {
+	e := NewEncoder()
+	e.SetIndexed(true)
+	e.EncodeString("Hello, World!")
+	e.EncodeBool(true)
+	e.EncodeFloat64(math.Pi)
+
+	d := NewDecoder(e.Data())
+	ad, err := d.At(2)
+	if err != nil {
+		t.Fatalf("Unable to get value 2: %s\n", err)
+	}
+
+	if f, err := ad.DecodeFloat64(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	} else if f != math.Pi {
+		t.Fatalf("Expected output %f to match input %f.\n", f, math.Pi)
+	}
+
+	if _, err := ad.DecodeBool(); err != ErrEOB {
+		t.Errorf("Expected end of buffer error but received: %v\n", err)
+	}
+
+	if s, err := d.DecodeString(); err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	} else if s != "Hello, World!" {
+		t.Fatalf("Expected output %s to match input %s.\n", s, "Hello, World!")
+	}
+
+	ad, err = d.At(1)
+	if err != nil {
+		t.Fatalf("Unable to get value 1: %s\n", err)
+	}
+
+	if _, err := ad.DecodeString(); err != ErrType {
+		t.Errorf("Expected a type mismatch error but received: %v\n", err)
+	}
+}
+
+func TestIndexSequential(t *testing.T) {
+	e := NewEncoder()
+	e.SetIndexed(true)
+	e.EncodeInt(1)
+	e.EncodeInt(2)
+
+	d := NewDecoder(e.Data())
+	for i := 1; i <= 2; i++ {
+		if n, err := d.DecodeInt(); err != nil {
+			t.Fatalf("Error decoding type: %s\n", err)
+		} else if n != i {
+			t.Fatalf("Expected output %d to match input %d.\n", n, i)
+		}
+	}
+
+	if _, err := d.DecodeInt(); err != ErrEOB {
+		t.Errorf("Expected end of buffer error but received: %v\n", err)
+	}
+}
+
+func TestNoIndex(t *testing.T) {
+	e := NewEncoder()
+	e.EncodeInt(1)
+
+	d := NewDecoder(e.Data())
+	if indexed, err := d.Indexed(); err != nil || indexed {
+		t.Errorf("Expected no index but received %t, %v.\n", indexed, err)
+	}
+
+	if err := d.Seek(0); err != ErrNoIndex {
+		t.Errorf("Expected a missing index error but received: %v\n", err)
+	}
+
+	if n, err := d.DecodeInt(); err != nil || n != 1 {
+		t.Errorf("Expected output 1 but received %d, %v.\n", n, err)
+	}
+}
+
+func TestIndexCompressDecompress(t *testing.T) {
+	e := NewEncoder()
+	e.SetIndexed(true)
+	e.EncodeString("a")
+	e.EncodeString("b")
+
+	cd, err := e.Compress()
+	if err != nil {
+		t.Fatalf("Unable to compress data: %s\n", err)
+	}
+
+	d := NewDecoder(cd)
+	if err = d.Decompress(); err != nil {
+		t.Fatalf("Unable to decompress data: %s\n", err)
+	}
+
+	if err = d.Seek(1); err != nil {
+		t.Fatalf("Unable to seek to value 1: %s\n", err)
+	}
+
+	if s, err := d.DecodeString(); err != nil || s != "b" {
+		t.Errorf("Expected output b but received %s, %v.\n", s, err)
+	}
+}
+
+// Any
+
+// testCircle is a Codable type for testing the type registry.
+type testCircle struct {
+	Name   string
+	Radius float64
+}
+
+func (c *testCircle) EncodeTo(e *Encoder) error {
+	if c.Radius < 0 {
+		return ErrValue
+	}
+
+	e.EncodeString(c.Name)
+	e.EncodeFloat64(c.Radius)
+	return nil
+}
+
+func (c *testCircle) DecodeFrom(d *Decoder) error {
+	var err error
+	if c.Name, err = d.DecodeString(); err != nil {
+		return err
+	}
+	c.Radius, err = d.DecodeFloat64()
+	return err
+}
+
+// testCelsius, testKelvin and testFahrenheit are named numeric types for
+// testing the type registry.
+type testCelsius float64
+type testKelvin float64
+type testFahrenheit float64
+
+func TestEncodeDecodeAny(t *testing.T) {
+	Register("test.circle", &testCircle{})
+	Register("test.celsius", testCelsius(0))
+	Register("test.time", time.Time{})
+
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	inputs := []interface{}{
+		&testCircle{Name: "unit", Radius: 1},
+		testCelsius(-40),
+		now,
+		nil,
+	}
+
+	e := NewEncoder()
+	e.SetStringTable(true)
+	for _, i := range inputs {
+		if err := e.EncodeAny(i); err != nil {
+			t.Fatalf("Unable to encode %v: %s\n", i, err)
+		}
+	}
+
+	if err := e.EncodeAny(&testCircle{Name: "bad", Radius: -1}); err != ErrValue {
+		t.Fatalf("Expected an invalid value error but received: %v\n", err)
+	}
+
+	if err := e.EncodeAny(42); err != ErrUnregistered {
+		t.Fatalf("Expected an unregistered type error but received: %v\n", err)
+	}
+	e.EncodeString("bad")
+
+	d := NewDecoder(e.Data())
+	for _, i := range inputs {
+		o, err := d.DecodeAny()
+		if err != nil {
+			t.Fatalf("Error decoding type: %s\n", err)
+		}
+
+		if fmt.Sprintf("%T %v", o, o) != fmt.Sprintf("%T %v", i, i) {
+			t.Fatalf("Expected output %T %v to match input %T %v.\n", o, o, i, i)
+		}
+	}
+
+	if s, err := d.DecodeString(); err != nil || s != "bad" {
+		t.Fatalf("Expected output bad but received %s, %v.\n", s, err)
+	}
+}
+
+func TestEncodeDecodeAnyNilData(t *testing.T) {
+	Register("test.bytes", []byte(nil))
+
+	inputs := []interface{}{[]byte(nil), []byte{}, []byte{1, 2}}
+
+	e := NewEncoder()
+	for _, i := range inputs {
+		if err := e.EncodeAny(i); err != nil {
+			t.Fatalf("Unable to encode %v: %s\n", i, err)
+		}
+	}
+
+	d := NewDecoder(e.Data())
+	for _, i := range inputs {
+		if o, err := d.DecodeAny(); err != nil || !reflect.DeepEqual(o, i) {
+			t.Errorf("Expected output %#v but received %#v, %v.\n", i, o, err)
+		}
+	}
+}
+
+func TestDecodeAnyUnregistered(t *testing.T) {
+	Register("test.kelvin", testKelvin(0))
+
+	e := NewEncoder()
+	if err := e.EncodeAny(testKelvin(1)); err != nil {
+		t.Fatalf("Unable to encode value: %s\n", err)
+	}
+	e.EncodeInt(7)
+
+	// Corrupt the type ID so that it isn't registered
+	e.data[9] ^= 0xFF
+
+	d := NewDecoder(e.Data())
+	if _, err := d.DecodeAny(); err != ErrUnregistered {
+		t.Fatalf("Expected an unregistered type error but received: %v\n", err)
+	}
+
+	if n, err := d.DecodeInt(); err != nil || n != 7 {
+		t.Errorf("Expected output 7 but received %d, %v.\n", n, err)
+	}
+}
+
+// testNode is a Codable type with pointers for testing reference tracking.
+type testNode struct {
+	Name string
+	Next *testNode
+	Peer *testCircle
+}
+
+func (n *testNode) EncodeTo(e *Encoder) error {
+	e.EncodeString(n.Name)
+	if err := e.EncodeAny(n.Next); err != nil {
+		return err
+	}
+	return e.EncodeAny(n.Peer)
+}
+
+func (n *testNode) DecodeFrom(d *Decoder) error {
+	var err error
+	if n.Name, err = d.DecodeString(); err != nil {
+		return err
+	}
+
+	next, err := d.DecodeAny()
+	if err != nil {
+		return err
+	}
+	n.Next, _ = next.(*testNode)
+
+	peer, err := d.DecodeAny()
+	if err != nil {
+		return err
+	}
+	n.Peer, _ = peer.(*testCircle)
+	return nil
+}
+
+func TestEncodeDecodeAnyReferences(t *testing.T) {
+	Register("test.circle", &testCircle{})
+	Register("test.node", &testNode{})
+
+	// A cycle of three nodes that share a circle
+	c := &testCircle{Name: "shared", Radius: 2}
+	a := &testNode{Name: "a", Peer: c}
+	b := &testNode{Name: "b", Next: a, Peer: c}
+	a.Next = &testNode{Name: "c", Next: b}
+
+	e := NewEncoder()
+	e.SetReferences(true)
+	if err := e.EncodeAny(a); err != nil {
+		t.Fatalf("Unable to encode graph: %s\n", err)
+	}
+	if err := e.EncodeAny(c); err != nil {
+		t.Fatalf("Unable to encode circle: %s\n", err)
+	}
+
+	d := NewDecoder(e.Data())
+	o, err := d.DecodeAny()
+	if err != nil {
+		t.Fatalf("Error decoding graph: %s\n", err)
+	}
+
+	oa, ok := o.(*testNode)
+	if !ok {
+		t.Fatalf("Expected a node but received %T.\n", o)
+	}
+
+	oc := oa.Next
+	ob := oc.Next
+	if oa.Name != "a" || ob.Name != "b" || oc.Name != "c" {
+		t.Fatalf("Expected nodes a, b and c but received %s, %s and %s.\n", oa.Name, ob.Name, oc.Name)
+	}
+
+	if ob.Next != oa {
+		t.Error("Expected the decoded nodes to form a cycle.")
+	}
+
+	if oa.Peer == nil || oa.Peer != ob.Peer || *oa.Peer != *c {
+		t.Errorf("Expected nodes a and b to share circle %v.\n", *c)
+	}
+
+	if o, err := d.DecodeAny(); err != nil || o != oa.Peer {
+		t.Errorf("Expected the shared circle but received %v, %v.\n", o, err)
+	}
+}
+
+func TestDecodeAnySkippedReference(t *testing.T) {
+	Register("test.circle", &testCircle{})
+
+	c := &testCircle{Name: "shared", Radius: 2}
+
+	e := NewEncoder()
+	e.SetIndexed(true)
+	e.SetReferences(true)
+	e.BeginSection()
+	if err := e.EncodeAny(c); err != nil {
+		t.Fatalf("Unable to encode circle: %s\n", err)
+	}
+	if err := e.EndSection(); err != nil {
+		t.Fatalf("Unable to end section: %s\n", err)
+	}
+	if err := e.EncodeAny(c); err != nil {
+		t.Fatalf("Unable to encode circle: %s\n", err)
+	}
+	if err := e.EncodeAny(c); err != nil {
+		t.Fatalf("Unable to encode circle: %s\n", err)
+	}
+
+	d := NewDecoder(e.Data())
+	if err := d.SkipSection(); err != nil {
+		t.Fatalf("Unable to skip section: %s\n", err)
+	}
+
+	o, err := d.DecodeAny()
+	if err != nil || *o.(*testCircle) != *c {
+		t.Fatalf("Expected circle %v after skipping its definition but received %v, %v.\n", *c, o, err)
+	}
+
+	if p, err := d.DecodeAny(); err != nil || p != o {
+		t.Errorf("Expected the same circle but received %v, %v.\n", p, err)
+	}
+
+	ad, err := NewDecoder(e.Data()).At(2)
+	if err != nil {
+		t.Fatalf("Unable to get decoder: %s\n", err)
+	}
+
+	if o, err := ad.DecodeAny(); err != nil || *o.(*testCircle) != *c {
+		t.Errorf("Expected circle %v after seeking past its definition but received %v, %v.\n", *c, o, err)
+	}
+}
+
+func TestDecodeAnyParallelSections(t *testing.T) {
+	Register("test.circle", &testCircle{})
+
+	// The definition is skipped so that every section decodes it
+	c := &testCircle{Name: "shared", Radius: 2}
+	e := NewEncoder()
+	e.SetReferences(true)
+	e.BeginSection()
+	_ = e.EncodeAny(c)
+	_ = e.EndSection()
+	for i := 0; i < 8; i++ {
+		e.BeginSection()
+		_ = e.EncodeAny(&testCircle{Name: fmt.Sprint(i)})
+		_ = e.EncodeAny(c)
+		_ = e.EndSection()
+	}
+
+	d := NewDecoder(e.Data())
+	if err := d.SkipSection(); err != nil {
+		t.Fatalf("Unable to skip section: %s\n", err)
+	}
+
+	var sds []*Decoder
+	for i := 0; i < 8; i++ {
+		sd, err := d.EnterSection()
+		if err != nil {
+			t.Fatalf("Unable to enter section: %s\n", err)
+		}
+		sds = append(sds, sd)
+	}
+
+	// Run with -race to check the sections' shared references
+	var wg sync.WaitGroup
+	vs := make([]interface{}, len(sds))
+	errs := make([]error, len(sds))
+	for i, sd := range sds {
+		wg.Add(1)
+		go func(i int, sd *Decoder) {
+			defer wg.Done()
+			if _, errs[i] = sd.DecodeAny(); errs[i] == nil {
+				vs[i], errs[i] = sd.DecodeAny()
+			}
+		}(i, sd)
+	}
+	wg.Wait()
+
+	for i, v := range vs {
+		if errs[i] != nil || v != vs[0] || *v.(*testCircle) != *c {
+			t.Errorf("Expected section %d to share circle %v but received %v, %v.\n", i, *c, v, errs[i])
+		}
+	}
+}
+
+func TestDecodeAnyRecordReference(t *testing.T) {
+	Register("test.circle", &testCircle{})
+
+	c := &testCircle{Name: "shared", Radius: 2}
+
+	e := NewEncoder()
+	e.SetReferences(true)
+	e.BeginRecord()
+	mustField(e, 1, t)
+	if err := e.EncodeAny(&testCircle{Name: "other"}); err != nil {
+		t.Fatalf("Unable to encode circle: %s\n", err)
+	}
+	if err := e.EndRecord(); err != nil {
+		t.Fatalf("Unable to end record: %s\n", err)
+	}
+	if err := e.EncodeAny(c); err != nil {
+		t.Fatalf("Unable to encode circle: %s\n", err)
+	}
+	if err := e.EncodeAny(c); err != nil {
+		t.Fatalf("Unable to encode circle: %s\n", err)
+	}
+
+	d := NewDecoder(e.Data())
+	r, err := d.DecodeRecord()
+	if err != nil {
+		t.Fatalf("Unable to decode record: %s\n", err)
+	}
+
+	// Decoding a field twice must not change what later references refer to
+	var first interface{}
+	for i := 0; i < 2; i++ {
+		fd, err := r.Field(1)
+		if err != nil {
+			t.Fatalf("Unable to get field: %s\n", err)
+		}
+
+		o, err := fd.DecodeAny()
+		if err != nil || o.(*testCircle).Name != "other" {
+			t.Fatalf("Expected the other circle but received %v, %v.\n", o, err)
+		}
+
+		if i == 0 {
+			first = o
+		} else if o != first {
+			t.Error("Expected the field's circle to be decoded to the same pointer.")
+		}
+	}
+
+	o, err := d.DecodeAny()
+	if err != nil || *o.(*testCircle) != *c {
+		t.Fatalf("Expected circle %v but received %v, %v.\n", *c, o, err)
+	}
+
+	if p, err := d.DecodeAny(); err != nil || p != o {
+		t.Errorf("Expected the shared circle but received %v, %v.\n", p, err)
+	}
+}
+
+func TestDecodeAnyInvalidReference(t *testing.T) {
+	for _, r := range [][]byte{{0}, {3}, {2}} {
+		e := NewEncoder()
+		e.EncodeInt8(1)
+		e.appendType(codingTypeAnyRef)
+		e.appendBytes(r)
+
+		d := NewDecoder(e.Data())
+		if _, err := d.DecodeInt8(); err != nil {
+			t.Fatalf("Error decoding type: %s\n", err)
+		}
+
+		if _, err := d.DecodeAny(); err != ErrReference {
+			t.Errorf("Expected an unknown reference error for %v but received: %v\n", r, err)
+		}
+	}
+}
+
+func TestRegisterConflicts(t *testing.T) {
+	Register("test.fahrenheit", testFahrenheit(0))
+	Register("test.fahrenheit", testFahrenheit(0))
+
+	for _, f := range []func(){
+		func() { Register("test.fahrenheit", 0) },
+		func() { Register("test.other", testFahrenheit(0)) },
+		func() { Register("test.struct", struct{}{}) },
+		func() { Register("", 0) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected Register to panic.")
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+// Set
+
+func TestEncodeDecodeSet(t *testing.T) {
+	e := NewEncoder()
+	inputs := []interface{}{
+		[]int64{3, 1, 3, -2},
+		[]string{"b", "a", "b", ""},
+		[]float64{1.5, math.Copysign(0, -1), 0, -1},
+		[]uint8{},
+	}
+	outputs := []interface{}{
+		[]int64{-2, 1, 3},
+		[]string{"", "a", "b"},
+		[]float64{-1, 0, 1.5},
+		[]uint8{},
+	}
+
+	for _, i := range inputs {
+		if err := e.EncodeSet(i); err != nil {
+			t.Fatalf("Unable to encode set %v: %s\n", i, err)
+		}
+	}
+
+	d := NewDecoder(e.Data())
+	for _, o := range outputs {
+		s, err := d.DecodeSet()
+		if err != nil {
+			t.Fatalf("Error decoding set: %s\n", err)
+		}
+
+		if fmt.Sprintf("%T %v", s, s) != fmt.Sprintf("%T %v", o, o) {
+			t.Errorf("Expected output %T %v but received %T %v.\n", o, o, s, s)
+		}
+	}
+
+	// The input slices are left unchanged
+	if fmt.Sprint(inputs[0]) != "[3 1 3 -2]" {
+		t.Errorf("Expected the input to be unchanged but received %v.\n", inputs[0])
+	}
+}
+
+func TestEncodeSetErrors(t *testing.T) {
+	e := NewEncoder()
+	if err := e.EncodeSet([]bool{true}); err != ErrType {
+		t.Errorf("Expected an incorrect type error but received: %v\n", err)
+	}
+
+	if err := e.EncodeSet([]float32{1, float32(math.NaN())}); err != ErrValue {
+		t.Errorf("Expected an invalid value error but received: %v\n", err)
+	}
+
+	e.SetStrictUTF8(true)
+	if err := e.EncodeSet([]string{"\xff"}); err != ErrUTF8 {
+		t.Errorf("Expected an invalid UTF-8 error but received: %v\n", err)
+	}
+}
+
+func TestDecodeSetDuplicates(t *testing.T) {
+	e := NewEncoder()
+	e.appendType(codingTypeSet)
+	e.appendByte(codingTypeUint8)
+	e.appendUvarint(2)
+	e.appendBytes([]byte{1, 1})
+
+	e.appendType(codingTypeSet)
+	e.appendByte(codingTypeString)
+	e.appendUvarint(2)
+	e.appendString("b")
+	e.appendString("a")
+
+	d := NewDecoder(e.Data())
+	if _, err := d.DecodeSet(); err != ErrSet {
+		t.Errorf("Expected an invalid set error but received: %v\n", err)
+	}
+
+	if _, err := d.DecodeSet(); err != ErrSet {
+		t.Errorf("Expected an invalid set error but received: %v\n", err)
+	}
+}
+
+// Map
+
+func TestOrderedMap(t *testing.T) {
+	m := NewOrderedMap()
+	m.Set("b", 1)
+	m.Set("a", 2)
+	m.Set("c", 3)
+	m.Set("b", 4)
+	m.Delete("a")
+	m.Delete("missing")
+
+	if fmt.Sprint(m.Keys()) != "[b c]" || m.Len() != 2 {
+		t.Fatalf("Expected keys [b c] but received %v.\n", m.Keys())
+	}
+
+	if v, ok := m.Get("b"); !ok || v != 4 {
+		t.Errorf("Expected value 4 but received %v, %t.\n", v, ok)
+	}
+
+	if _, ok := m.Get("a"); ok {
+		t.Error("Expected deleted key to be missing.")
+	}
+}
+
+func TestEncodeDecodeMap(t *testing.T) {
+	Register("test.int", 0)
+	Register("test.string", "")
+
+	m := NewOrderedMap()
+	m.Set("zeta", 1)
+	m.Set("alpha", "two")
+	m.Set("mu", nil)
+
+	e := NewEncoder()
+	if err := e.EncodeOrderedMap(m); err != nil {
+		t.Fatalf("Unable to encode map: %s\n", err)
+	}
+	if err := e.EncodeSortedMap(m); err != nil {
+		t.Fatalf("Unable to encode map: %s\n", err)
+	}
+
+	d := NewDecoder(e.Data())
+	for _, keys := range []string{"[zeta alpha mu]", "[alpha mu zeta]"} {
+		o, err := d.DecodeMap()
+		if err != nil {
+			t.Fatalf("Error decoding map: %s\n", err)
+		}
+
+		if fmt.Sprint(o.Keys()) != keys {
+			t.Errorf("Expected keys %s but received %v.\n", keys, o.Keys())
+		}
+
+		for _, k := range m.Keys() {
+			v, _ := m.Get(k)
+			if ov, ok := o.Get(k); !ok || ov != v {
+				t.Errorf("Expected value %v for %s but received %v.\n", v, k, ov)
+			}
+		}
+	}
+}
+
+func TestEncodeMapErrors(t *testing.T) {
+	m := NewOrderedMap()
+	m.Set("ok", nil)
+	m.Set("bad", struct{}{})
+
+	e := NewEncoder()
+	if err := e.EncodeOrderedMap(m); err != ErrUnregistered {
+		t.Fatalf("Expected an unregistered type error but received: %v\n", err)
+	}
+
+	if len(e.data) != 0 {
+		t.Errorf("Expected nothing to be encoded but received %d bytes.\n", len(e.data))
+	}
+}
+
+func TestEncodeNormalizedSetAndMap(t *testing.T) {
+	// Composes e followed by a combining acute accent, as NFC would
+	nfc := func(s string) string {
+		return strings.ReplaceAll(s, "e\u0301", "\u00e9")
+	}
+
+	e := NewEncoder()
+	e.SetNormalizer(nfc)
+	if err := e.EncodeSet([]string{"e\u0301", "\u00e9", "f"}); err != nil {
+		t.Fatalf("Unable to encode set: %s\n", err)
+	}
+
+	// Normalizing moves the first key after the second
+	m := NewOrderedMap()
+	m.Set("e\u0301", nil)
+	m.Set("f", nil)
+	if err := e.EncodeSortedMap(m); err != nil {
+		t.Fatalf("Unable to encode map: %s\n", err)
+	}
+
+	m.Set("\u00e9", nil)
+	if err := e.EncodeOrderedMap(m); err != ErrMap {
+		t.Fatalf("Expected an invalid map error but received: %v\n", err)
+	}
+
+	d := NewDecoder(e.Data())
+	if o, err := d.DecodeSet(); err != nil || fmt.Sprint(o) != "[f \u00e9]" {
+		t.Errorf("Expected set [f \u00e9] but received %v, %v.\n", o, err)
+	}
+
+	if o, err := d.DecodeMap(); err != nil || fmt.Sprint(o.Keys()) != "[f \u00e9]" {
+		t.Errorf("Expected keys [f \u00e9] but received %v.\n", err)
+	}
+}
+
+func TestDecodeMapInvalid(t *testing.T) {
+	// A map with a duplicate key and a sorted map that isn't sorted
+	for _, m := range []struct {
+		order byte
+		keys  []string
+	}{
+		{mapInsertionOrder, []string{"a", "a"}},
+		{mapSorted, []string{"b", "a"}},
+	} {
+		e := NewEncoder()
+		e.appendType(codingTypeMap)
+		e.appendByte(m.order)
+		e.appendUvarint(uint64(len(m.keys)))
+		for _, k := range m.keys {
+			e.appendString(k)
+			e.EncodeNil()
+		}
+
+		d := NewDecoder(e.Data())
+		if _, err := d.DecodeMap(); err != ErrMap {
+			t.Errorf("Expected an invalid map error for keys %v but received: %v\n", m.keys, err)
+		}
+	}
+}
+
+// Enum
+
+// testColor and testLevel are enum types for testing enum registration.
+type testColor int
+type testLevel uint8
+
+const (
+	testColorRed testColor = iota
+	testColorGreen
+	testColorBlue
+)
+
+func init() {
+	RegisterEnum(map[testColor]string{
+		testColorRed:   "red",
+		testColorGreen: "green",
+		testColorBlue:  "blue",
+	})
+	RegisterEnum(map[testLevel]string{
+		1:   "low",
+		255: "high",
+	})
+}
+
+func TestEncodeDecodeEnum(t *testing.T) {
+	for _, names := range []bool{false, true} {
+		e := NewEncoder()
+		e.SetEnumNames(names)
+		if err := e.EncodeEnum(testColorBlue); err != nil {
+			t.Fatalf("Unable to encode enum: %s\n", err)
+		}
+		if err := e.EncodeEnum(testLevel(255)); err != nil {
+			t.Fatalf("Unable to encode enum: %s\n", err)
+		}
+
+		d := NewDecoder(e.Data())
+		var c testColor
+		if err := d.DecodeEnum(&c); err != nil || c != testColorBlue {
+			t.Fatalf("Expected output %d but received %d, %v.\n", testColorBlue, c, err)
+		}
+
+		var l testLevel
+		if err := d.DecodeEnum(&l); err != nil || l != 255 {
+			t.Fatalf("Expected output 255 but received %d, %v.\n", l, err)
+		}
+	}
+}
+
+func TestEncodeDecodeEnumNames(t *testing.T) {
+	e := NewEncoder()
+	e.SetEnumNames(true)
+	if err := e.EncodeEnum(testColorGreen); err != nil {
+		t.Fatalf("Unable to encode enum: %s\n", err)
+	}
+
+	if !bytes.Contains(e.Data(), []byte("green")) {
+		t.Error("Expected the encoded data to contain the enum's name.")
+	}
+}
+
+func TestEncodeDecodeEnumErrors(t *testing.T) {
+	e := NewEncoder()
+	if err := e.EncodeEnum(testColor(7)); err != ErrEnum {
+		t.Fatalf("Expected an unknown enum value error but received: %v\n", err)
+	}
+
+	if err := e.EncodeEnum(7); err != ErrUnregistered {
+		t.Fatalf("Expected an unregistered type error but received: %v\n", err)
+	}
+
+	// A level encoded with a value that isn't a color
+	if err := e.EncodeEnum(testLevel(255)); err != nil {
+		t.Fatalf("Unable to encode enum: %s\n", err)
+	}
+	e.SetEnumNames(true)
+	if err := e.EncodeEnum(testLevel(1)); err != nil {
+		t.Fatalf("Unable to encode enum: %s\n", err)
+	}
+
+	d := NewDecoder(e.Data())
+	var c testColor
+	if err := d.DecodeEnum(&c); err != ErrEnum {
+		t.Errorf("Expected an unknown enum value error but received: %v\n", err)
+	}
+	if err := d.DecodeEnum(&c); err != ErrEnum {
+		t.Errorf("Expected an unknown enum value error but received: %v\n", err)
+	}
+
+	if err := d.DecodeEnum(c); err != ErrUnregistered {
+		t.Errorf("Expected an unregistered type error but received: %v\n", err)
+	}
+}
+
+func TestRegisterEnumConflicts(t *testing.T) {
+	for _, f := range []func(){
+		func() { RegisterEnum(map[testColor]string{testColorRed: "red"}) },
+		func() { RegisterEnum(map[int8]string{1: "a", 2: "a"}) },
+		func() { RegisterEnum(map[string]string{"a": "a"}) },
+		func() { RegisterEnum(nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected RegisterEnum to panic.")
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+// Section
+
+func TestEnterSection(t *testing.T) {
+	e := NewEncoder()
+	e.BeginSection()
+	e.EncodeString("header")
+	e.BeginSection()
+	e.EncodeInt(42)
+	if err := e.EndSection(); err != nil {
+		t.Fatalf("Unable to end section: %s\n", err)
+	}
+	if err := e.EndSection(); err != nil {
+		t.Fatalf("Unable to end section: %s\n", err)
+	}
+	e.EncodeBool(true)
+
+	d := NewDecoder(e.Data())
+	sd, err := d.EnterSection()
+	if err != nil {
+		t.Fatalf("Unable to enter section: %s\n", err)
+	}
+
+	if s, err := sd.DecodeString(); err != nil || s != "header" {
+		t.Fatalf("Expected output header but received %s, %v.\n", s, err)
+	}
+
+	nd, err := sd.EnterSection()
+	if err != nil {
+		t.Fatalf("Unable to enter nested section: %s\n", err)
+	}
+
+	if n, err := nd.DecodeInt(); err != nil || n != 42 {
+		t.Fatalf("Expected output 42 but received %d, %v.\n", n, err)
+	}
+
+	if _, err := nd.DecodeInt(); err != ErrEOB {
+		t.Errorf("Expected end of buffer error but received: %v\n", err)
+	}
+
+	if _, err := sd.DecodeInt(); err != ErrEOB {
+		t.Errorf("Expected end of buffer error but received: %v\n", err)
+	}
+
+	if b, err := d.DecodeBool(); err != nil || !b {
+		t.Errorf("Expected output true but received %t, %v.\n", b, err)
+	}
+}
+
+func TestSkipSection(t *testing.T) {
+	e := NewEncoder()
+	e.SetIndexed(true)
+	e.BeginSection()
+	e.EncodeString("extension")
+	e.EncodeFloat64(math.E)
+	if err := e.EndSection(); err != nil {
+		t.Fatalf("Unable to end section: %s\n", err)
+	}
+	e.EncodeInt(7)
+
+	d := NewDecoder(e.Data())
+	if n, err := d.IndexLen(); err != nil || n != 2 {
+		t.Fatalf("Expected 2 indexed values but received %d, %v.\n", n, err)
+	}
+
+	if err := d.SkipSection(); err != nil {
+		t.Fatalf("Unable to skip section: %s\n", err)
+	}
+
+	if n, err := d.DecodeInt(); err != nil || n != 7 {
+		t.Errorf("Expected output 7 but received %d, %v.\n", n, err)
+	}
+}
+
+func TestSectionErrors(t *testing.T) {
+	e := NewEncoder()
+	if err := e.EndSection(); err != ErrNoSection {
+		t.Errorf("Expected a missing section error but received: %v\n", err)
+	}
+
+	e.EncodeInt(1)
+	d := NewDecoder(e.Data())
+	if err := d.SkipSection(); err != ErrType {
+		t.Errorf("Expected a type mismatch error but received: %v\n", err)
+	}
+}
+
+func TestDataOpenBlock(t *testing.T) {
+	for _, begin := range []func(e *Encoder){
+		func(e *Encoder) { e.BeginSection() },
+		func(e *Encoder) { e.BeginRecord() },
+		func(e *Encoder) {
+			e.BeginSection()
+			e.BeginSection()
+			_ = e.EndSection()
+		},
+	} {
+		e := NewEncoder()
+		e.EncodeInt(1)
+		begin(e)
+		e.EncodeInt(2)
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected Data to panic with an open block.")
+				}
+			}()
+			e.Data()
+		}()
+	}
+}
+
+// Record
+
+func TestRecordFields(t *testing.T) {
+	// A newer writer adds field 3 between fields 1 and 2
+	e := NewEncoder()
+	e.BeginRecord()
+	mustField(e, 1, t)
+	e.EncodeString("name")
+	mustField(e, 3, t)
+	e.EncodeFloat64(math.Pi)
+	e.EncodeBool(true)
+	mustField(e, 2, t)
+	e.EncodeInt(42)
+	if err := e.EndRecord(); err != nil {
+		t.Fatalf("Unable to end record: %s\n", err)
+	}
+	e.EncodeString("after")
+
+	// An older reader only knows about fields 1, 2 and 4
+	d := NewDecoder(e.Data())
+	r, err := d.DecodeRecord()
+	if err != nil {
+		t.Fatalf("Unable to decode record: %s\n", err)
+	}
+
+	fd, err := r.Field(2)
+	if err != nil {
+		t.Fatalf("Unable to get field 2: %s\n", err)
+	}
+
+	if n, err := fd.DecodeInt(); err != nil || n != 42 {
+		t.Fatalf("Expected output 42 but received %d, %v.\n", n, err)
+	}
+
+	fd, err = r.Field(1)
+	if err != nil {
+		t.Fatalf("Unable to get field 1: %s\n", err)
+	}
+
+	if s, err := fd.DecodeString(); err != nil || s != "name" {
+		t.Fatalf("Expected output name but received %s, %v.\n", s, err)
+	}
+
+	if _, err := r.Field(4); err != ErrMissingField {
+		t.Errorf("Expected a missing field error but received: %v\n", err)
+	}
+
+	if m := r.Missing(1, 2, 4); len(m) != 1 || m[0] != 4 {
+		t.Errorf("Expected missing field 4 but received %v.\n", m)
+	}
+
+	if fs := r.Fields(); len(fs) != 3 || fs[0] != 1 || fs[1] != 2 || fs[2] != 3 {
+		t.Errorf("Expected fields [1 2 3] but received %v.\n", fs)
+	}
+
+	if s, err := d.DecodeString(); err != nil || s != "after" {
+		t.Errorf("Expected output after but received %s, %v.\n", s, err)
+	}
+}
+
+func TestNestedRecord(t *testing.T) {
+	e := NewEncoder()
+	e.BeginRecord()
+	mustField(e, 1, t)
+	e.BeginRecord()
+	mustField(e, 5, t)
+	e.EncodeUint8(8)
+	if err := e.EndSection(); err != ErrNoSection {
+		t.Errorf("Expected a missing section error but received: %v\n", err)
+	}
+	if err := e.EndRecord(); err != nil {
+		t.Fatalf("Unable to end record: %s\n", err)
+	}
+	if err := e.EndRecord(); err != nil {
+		t.Fatalf("Unable to end record: %s\n", err)
+	}
+
+	d := NewDecoder(e.Data())
+	r, err := d.DecodeRecord()
+	if err != nil {
+		t.Fatalf("Unable to decode record: %s\n", err)
+	}
+
+	fd, err := r.Field(1)
+	if err != nil {
+		t.Fatalf("Unable to get field 1: %s\n", err)
+	}
+
+	nr, err := fd.DecodeRecord()
+	if err != nil {
+		t.Fatalf("Unable to decode nested record: %s\n", err)
+	}
+
+	fd, err = nr.Field(5)
+	if err != nil {
+		t.Fatalf("Unable to get field 5: %s\n", err)
+	}
+
+	if n, err := fd.DecodeUint8(); err != nil || n != 8 {
+		t.Errorf("Expected output 8 but received %d, %v.\n", n, err)
+	}
+}
+
+func TestRecordErrors(t *testing.T) {
+	e := NewEncoder()
+	if err := e.Field(1); err != ErrNoRecord {
+		t.Errorf("Expected a missing record error but received: %v\n", err)
+	}
+
+	if err := e.EndRecord(); err != ErrNoRecord {
+		t.Errorf("Expected a missing record error but received: %v\n", err)
+	}
+
+	e.BeginRecord()
+	if err := e.Field(-1); err != ErrFieldNumber {
+		t.Errorf("Expected an invalid field number error but received: %v\n", err)
+	}
+}
+
+func TestRecordValueBeforeField(t *testing.T) {
+	e := NewEncoder()
+	e.BeginRecord()
+	e.EncodeInt8(1)
+	mustField(e, 1, t)
+	e.EncodeInt8(2)
+	if err := e.EndRecord(); err != ErrNoField {
+		t.Errorf("Expected a missing field error but received: %v\n", err)
+	}
+
+	if err := e.Err(); err != ErrNoField {
+		t.Errorf("Expected a missing field error but received: %v\n", err)
+	}
+
+	// Values in a field's nested record still need fields
+	e = NewEncoder()
+	e.BeginRecord()
+	mustField(e, 1, t)
+	e.BeginRecord()
+	mustField(e, 2, t)
+	e.EncodeInt8(1)
+	if err := e.EndRecord(); err != nil {
+		t.Fatalf("Unable to end record: %s\n", err)
+	}
+	e.EncodeInt8(2)
+	if err := e.EndRecord(); err != nil || e.Err() != nil {
+		t.Errorf("Expected no errors but received %v, %v.\n", err, e.Err())
+	}
+}
+
+// JSON
+
+// testJSONEncoder returns an encoder with one of each kind of value.
+func testJSONEncoder(t *testing.T) *Encoder {
+	Register("test.circle", &testCircle{})
+
+	e := NewEncoder()
+	e.EncodeBool(true)
+	e.EncodeInt(-1)
+	e.EncodeInt64(-1 << 40)
+	e.EncodeInt32(1 << 20)
+	e.EncodeInt16(-300)
+	e.EncodeInt8(-3)
+	e.EncodeUint(7)
+	e.EncodeUint64(1 << 50)
+	e.EncodeUint32(1 << 20)
+	e.EncodeUint16(300)
+	e.EncodeUint8(100)
+	e.EncodeFloat64(math.Pi)
+	e.EncodeFloat64(math.NaN())
+	e.EncodeFloat64(math.Copysign(0, -1))
+	e.EncodeFloat32(0.1)
+	e.EncodeFloat32(float32(math.Inf(-1)))
+	e.EncodeFloat16(1.5)
+	e.EncodeBFloat16(-2.5)
+	e.EncodeComplex128(complex(1, -2))
+	e.EncodeComplex64(complex(0.5, 0.25))
+	e.EncodeInt128(Int128{Hi: -2, Lo: 5})
+	e.EncodeUint128(Uint128{Hi: math.MaxUint64, Lo: 1})
+	e.EncodeString("héllo \"world\" <3")
+	e.EncodeData([]byte{1, 2, 3})
+	e.EncodeFloat64s([]float64{1, math.Inf(1)})
+	e.EncodeInt16s([]int16{-1, 2})
+	e.EncodeUint8s([]uint8{4, 5})
+	e.EncodeFloat16s([]float32{0.5, 1})
+	e.EncodeBFloat16s([]float32{2})
+	_ = e.EncodeArray([4]byte{9, 8, 7, 6})
+	_ = e.EncodeArray([2]float32{1, 2})
+	e.EncodeBools([]bool{true, false, true})
+
+	x, err := NewTensor([]int{2, 2}, []float32{1, 2, 3, 4})
+	if err != nil {
+		t.Fatalf("Unable to create tensor: %s\n", err)
+	}
+	e.EncodeTensor(x)
+
+	e.EncodeSparseFloat64s([]float64{0, 1.5, 0, 0, -2})
+	e.EncodeInt64Series([]int64{100, 200, 300})
+	e.EncodeFloat64Series([]float64{20.5, 20.5, 21})
+	e.EncodeBigInt(new(big.Int).Lsh(big.NewInt(-1), 100))
+	e.EncodeBigRat(big.NewRat(-1, 3))
+	e.EncodeBigFloat(new(big.Float).SetPrec(100).SetMode(big.ToZero).SetFloat64(1.1))
+	e.EncodeDecimal(NewDecimal(123450, 4))
+	e.EncodeDecimal(NewDecimal(12, -3))
+	e.EncodeTime(time.Date(2021, 6, 1, 12, 30, 0, 5, time.UTC))
+	e.EncodeTime(time.Date(2021, 6, 1, 12, 30, 0, 0, time.FixedZone("", 0)))
+	e.EncodeTime(time.Date(2021, 6, 1, 12, 30, 0, 0, time.FixedZone("ABC", 3600)))
+	e.EncodeTime(time.Date(-1, 2, 29, 0, 0, 0, 1, time.UTC))
+	e.EncodeTime(time.Date(10000, 1, 1, 0, 0, 0, 0, time.FixedZone("", -7200)))
+	e.EncodeDuration(90 * time.Minute)
+
+	u, _ := ParseUUID("123e4567-e89b-12d3-a456-426614174000")
+	e.EncodeUUID(u)
+	e.EncodeAddr(netip.MustParseAddr("fe80::1%eth0"))
+	e.EncodeAddr(netip.Addr{})
+	e.EncodePrefix(netip.MustParsePrefix("10.0.0.0/8"))
+	e.EncodeAddrPort(netip.MustParseAddrPort("[::1]:443"))
+	e.EncodeURL(&url.URL{Scheme: "https", Host: "example.com", Path: "/a b"})
+	e.EncodeNil()
+
+	_ = e.EncodeEnum(testColorGreen)
+	e.SetEnumNames(true)
+	_ = e.EncodeEnum(testColorBlue)
+
+	_ = e.EncodeSet([]string{"b", "a"})
+	_ = e.EncodeSet([]int32{3, -1})
+
+	m := NewOrderedMap()
+	m.Set("z", &testCircle{Name: "c", Radius: 1})
+	m.Set("a", nil)
+	_ = e.EncodeOrderedMap(m)
+	_ = e.EncodeSortedMap(m)
+
+	e.BeginSection()
+	e.EncodeInt8(1)
+	e.BeginSection()
+	_ = e.EndSection()
+	_ = e.EndSection()
+
+	e.BeginRecord()
+	_ = e.Field(1)
+	e.EncodeUint8(1)
+	e.EncodeUint8(2)
+	_ = e.Field(3)
+	_ = e.EndRecord()
+
+	c := &testCircle{Name: "shared", Radius: 2}
+	e.SetReferences(true)
+	_ = e.EncodeAny(c)
+	_ = e.EncodeAny(c)
+	return e
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	data := testJSONEncoder(t).Data()
+
+	j, err := ToJSON(data)
+	if err != nil {
+		t.Fatalf("Unable to convert data to JSON: %s\n", err)
+	}
+
+	if !json.Valid(j) {
+		t.Fatalf("Expected valid JSON but received %s\n", j)
+	}
+
+	for _, s := range []string{
+		`{"int8":-3}`,
+		`{"uint64":1125899906842624}`,
+		`{"float32":0.1}`,
+		`{"float64":"NaN"}`,
+		`{"bytes":"AQID"}`,
+		`{"[4]uint8":"CQgHBg=="}`,
+		`{"[]int16":[-1,2]}`,
+		`{"bitset":"101"}`,
+		`{"decimal":"12.3450"}`,
+		`{"time":{"sec":1622547000,"nsec":0,"offset":3600,"zone":"ABC"}}`,
+		`{"enum":1}`,
+		`{"enum":"blue"}`,
+		`{"map":[["z",{"any":`,
+		`{"anyref":0}`,
+	} {
+		if !bytes.Contains(j, []byte(s)) {
+			t.Errorf("Expected JSON to contain %s.\n", s)
+		}
+	}
+
+	o, err := FromJSON(j, true)
+	if err != nil {
+		t.Fatalf("Unable to convert JSON to data: %s\n", err)
+	}
+
+	if !bytes.Equal(o, data) {
+		t.Errorf("Expected the round trip to reproduce the data.\nJSON: %s\n", j)
+	}
+
+	if oj, err := ToJSON(o); err != nil || !bytes.Equal(oj, j) {
+		t.Errorf("Expected the round trip to reproduce the JSON but received %s, %v.\n", oj, err)
+	}
+}
+
+func TestJSONRoundTripReferences(t *testing.T) {
+	Register("test.circle", &testCircle{})
+	Register("test.node", &testNode{})
+
+	c := &testCircle{Name: "shared", Radius: 2}
+	a := &testNode{Name: "a", Peer: c}
+	a.Next = &testNode{Name: "a", Next: a, Peer: c}
+
+	// String table and run-length values change size in the round trip, so
+	// references after them must be recomputed
+	e := NewEncoder()
+	e.SetStringTable(true)
+	e.SetRunLength(true)
+	e.SetReferences(true)
+	e.EncodeString("shared")
+	e.EncodeInt32s(make([]int32, 64))
+	if err := e.EncodeAny(a); err != nil {
+		t.Fatalf("Unable to encode graph: %s\n", err)
+	}
+
+	// Fields in descending order with a reference from the first to the second
+	e.BeginRecord()
+	_ = e.Field(2)
+	_ = e.EncodeAny(c)
+	_ = e.Field(1)
+	_ = e.EncodeAny(c)
+	_ = e.EncodeAny(a)
+	if err := e.EndRecord(); err != nil {
+		t.Fatalf("Unable to end record: %s\n", err)
+	}
+
+	j, err := ToJSON(e.Data())
+	if err != nil {
+		t.Fatalf("Unable to convert data to JSON: %s\n", err)
+	}
+
+	o, err := FromJSON(j, true)
+	if err != nil {
+		t.Fatalf("Unable to convert JSON to data: %s\n", err)
+	}
+
+	if oj, err := ToJSON(o); err != nil || !bytes.Equal(oj, j) {
+		t.Errorf("Expected the round trip to reproduce the JSON but received %s, %v.\n", oj, err)
+	}
+
+	d := NewDecoder(o)
+	if s, err := d.DecodeString(); err != nil || s != "shared" {
+		t.Errorf("Expected output shared but received %s, %v.\n", s, err)
+	}
+
+	if s, err := d.DecodeInt32s(); err != nil || len(s) != 64 {
+		t.Errorf("Expected 64 elements but received %d, %v.\n", len(s), err)
+	}
+
+	v, err := d.DecodeAny()
+	if err != nil {
+		t.Fatalf("Error decoding graph: %s\n", err)
+	}
+
+	oa, ok := v.(*testNode)
+	if !ok || oa.Next == nil || oa.Next.Next != oa || oa.Peer != oa.Next.Peer || *oa.Peer != *c {
+		t.Fatalf("Expected a cycle of two nodes sharing a circle but received %v.\n", v)
+	}
+
+	r, err := d.DecodeRecord()
+	if err != nil {
+		t.Fatalf("Unable to decode record: %s\n", err)
+	}
+
+	if fields := r.Fields(); len(fields) != 2 {
+		t.Fatalf("Expected two fields but received %v.\n", fields)
+	}
+
+	fd, _ := r.Field(1)
+	if v, err := fd.DecodeAny(); err != nil || v != oa.Peer {
+		t.Errorf("Expected the shared circle but received %v, %v.\n", v, err)
+	}
+
+	if v, err := fd.DecodeAny(); err != nil || v != oa {
+		t.Errorf("Expected the first node but received %v, %v.\n", v, err)
+	}
+}
+
+func TestJSONIndexed(t *testing.T) {
+	e := NewEncoder()
+	e.SetIndexed(true)
+	e.EncodeInt8(1)
+	e.EncodeString("a")
+
+	j, err := ToJSON(e.Data())
+	if err != nil {
+		t.Fatalf("Unable to convert data to JSON: %s\n", err)
+	}
+
+	if string(j) != `{"indexed":[{"int8":1},{"string":"a"}]}` {
+		t.Fatalf("Unexpected JSON %s\n", j)
+	}
+
+	o, err := FromJSON(j, true)
+	if err != nil {
+		t.Fatalf("Unable to convert JSON to data: %s\n", err)
+	}
+
+	if !bytes.Equal(o, e.Data()) {
+		t.Error("Expected the round trip to reproduce the indexed data.")
+	}
+}
+
+func TestFromJSONPlain(t *testing.T) {
+	data, err := FromJSON([]byte(`[1, -2.5, "a", true, null, [1e3], {"k": "v", "a": 2}]`), false)
+	if err != nil {
+		t.Fatalf("Unable to convert JSON to data: %s\n", err)
+	}
+
+	d := NewDecoder(data)
+	if n, err := d.DecodeInt64(); err != nil || n != 1 {
+		t.Errorf("Expected output 1 but received %d, %v.\n", n, err)
+	}
+
+	if f, err := d.DecodeFloat64(); err != nil || f != -2.5 {
+		t.Errorf("Expected output -2.5 but received %f, %v.\n", f, err)
+	}
+
+	if s, err := d.DecodeString(); err != nil || s != "a" {
+		t.Errorf("Expected output a but received %s, %v.\n", s, err)
+	}
+
+	if b, err := d.DecodeBool(); err != nil || !b {
+		t.Errorf("Expected output true but received %t, %v.\n", b, err)
+	}
+
+	if isNil, err := d.DecodeNil(); err != nil || !isNil {
+		t.Errorf("Expected a nil value but received %t, %v.\n", isNil, err)
+	}
+
+	sd, err := d.EnterSection()
+	if err != nil {
+		t.Fatalf("Unable to enter section: %s\n", err)
+	}
+
+	if f, err := sd.DecodeFloat64(); err != nil || f != 1000 {
+		t.Errorf("Expected output 1000 but received %f, %v.\n", f, err)
+	}
+
+	od, err := d.EnterSection()
+	if err != nil {
+		t.Fatalf("Unable to enter section: %s\n", err)
+	}
+
+	k, _ := od.DecodeString()
+	v, _ := od.DecodeString()
+	a, _ := od.DecodeString()
+	n, err := od.DecodeInt64()
+	if k != "k" || v != "v" || a != "a" || n != 2 || err != nil {
+		t.Errorf("Expected the object's keys and values in order but received %s %s %s %d, %v.\n", k, v, a, n, err)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	for _, j := range []string{
+		``,
+		`{}`,
+		`[1] 2`,
+		`[{"int8":300}]`,
+		`[{"uint8":-1}]`,
+		`[{"int":1.5}]`,
+		`[{"int8":1,"int16":1}]`,
+		`[{"unknown":1}]`,
+		`[{"[3]uint8":"AQI="}]`,
+		`[{"bitset":"102"}]`,
+		`[{"time":{"sec":0,"nsec":1000000000}}]`,
+		`[{"time":{"sec":0,"nsec":0,"offset":0}}]`,
+		`[{"map":[["a",null],["a",null]]}]`,
+		`[{"sortedmap":[["b",null],["a",null]]}]`,
+	} {
+		if _, err := FromJSON([]byte(j), true); err == nil {
+			t.Errorf("Expected an error converting %s.\n", j)
+		}
+	}
+
+	for _, c := range []struct {
+		j         string
+		annotated bool
+	}{
+		{`[9223372036854775807]`, false},
+		{`[-9223372036854775808]`, false},
+		{`[{"int64":9223372036854775807}]`, true},
+		{`[{"uint64":18446744073709551615}]`, true},
+		{`[{"uint":18446744073709551615}]`, true},
+		{`[{"int8":-128}]`, true},
+		{`[{"uint16":65535}]`, true},
+	} {
+		if _, err := FromJSON([]byte(c.j), c.annotated); err != ErrJSON {
+			t.Errorf("Expected a JSON error converting %s but received: %v\n", c.j, err)
+		}
+	}
+
+	e := NewEncoder()
+	e.EncodeString("\xff")
+	if _, err := ToJSON(e.Data()); err != ErrUTF8 {
+		t.Errorf("Expected an invalid UTF-8 error but received: %v\n", err)
+	}
+
+	data := e.Data()
+	data[0] ^= 0xFF
+	if _, err := ToJSON(data); err != ErrCRC {
+		t.Errorf("Expected a CRC error but received: %v\n", err)
+	}
+}
+
+// Non-exported functions
+
+// testEncodeDecode attempts to encode the input value and then decode it.
+func testEncodeDecode(i interface{}, t *testing.T) {
+	e := NewEncoder()
+
+	switch i.(type) {
+	case bool:
+		e.EncodeBool(i.(bool))
+	case int:
+		e.EncodeInt(i.(int))
+	case int64:
+		e.EncodeInt64(i.(int64))
+	case int32:
+		e.EncodeInt32(i.(int32))
+	case int16:
+		e.EncodeInt16(i.(int16))
+	case int8:
+		e.EncodeInt8(i.(int8))
+	case uint:
+		e.EncodeUint(i.(uint))
+	case uint64:
+		e.EncodeUint64(i.(uint64))
+	case uint32:
+		e.EncodeUint32(i.(uint32))
+	case uint16:
+		e.EncodeUint16(i.(uint16))
+	case uint8:
+		e.EncodeUint8(i.(uint8))
+	case float64:
+		e.EncodeFloat64(i.(float64))
+	case float32:
+		e.EncodeFloat32(i.(float32))
+	case string:
+		e.EncodeString(i.(string))
+	case []byte:
+		e.EncodeData(i.([]byte))
+	case time.Duration:
+		e.EncodeDuration(i.(time.Duration))
+	case Decimal:
+		e.EncodeDecimal(i.(Decimal))
+	case complex128:
+		e.EncodeComplex128(i.(complex128))
+	case complex64:
+		e.EncodeComplex64(i.(complex64))
+	case Int128:
+		e.EncodeInt128(i.(Int128))
+	case Uint128:
+		e.EncodeUint128(i.(Uint128))
+	}
+
+	d := NewDecoder(e.Data())
+
+	var o interface{}
+	var err error
+
+	switch i.(type) {
+	case bool:
+		o, err = d.DecodeBool()
+	case int:
+		o, err = d.DecodeInt()
+	case int64:
+		o, err = d.DecodeInt64()
+	case int32:
+		o, err = d.DecodeInt32()
+	case int16:
+		o, err = d.DecodeInt16()
+	case int8:
+		o, err = d.DecodeInt8()
+	case uint:
+		o, err = d.DecodeUint()
+	case uint64:
+		o, err = d.DecodeUint64()
+	case uint32:
+		o, err = d.DecodeUint32()
+	case uint16:
+		o, err = d.DecodeUint16()
+	case uint8:
+		o, err = d.DecodeUint8()
+	case float64:
+		o, err = d.DecodeFloat64()
+	case float32:
+		o, err = d.DecodeFloat32()
+	case string:
+		o, err = d.DecodeString()
+	case []byte:
+		o, err = d.DecodeData()
+	case time.Duration:
+		o, err = d.DecodeDuration()
+	case Decimal:
+		o, err = d.DecodeDecimal()
+	case complex128:
+		o, err = d.DecodeComplex128()
+	case complex64:
+		o, err = d.DecodeComplex64()
+	case Int128:
+		o, err = d.DecodeInt128()
+	case Uint128:
+		o, err = d.DecodeUint128()
+	}
+
+	if err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+
+	switch i.(type) {
+	case []byte:
+		ib := i.([]byte)
+		ob := o.([]byte)
+
+		if len(ib) == len(ob) {
+			for j, v := range ib {
+				if v != ob[j] {
+					t.Fatalf("Mismatched values %d and %d.\n", v, ob[j])
+				}
+			}
+		} else {
+			t.Fatalf("Data has unequal length %d != %d.\n", len(ib), len(ob))
+		}
+	default:
 		if i != o {
 			t.Fatalf("Expected output %v to match input %v.\n", o, i)
 		}
 	}
 }
 
+// testEncodeDecodeTime attempts to encode the input time and then decode it.
+func testEncodeDecodeTime(i time.Time, t *testing.T) {
+	e := NewEncoder()
+	e.EncodeTime(i)
+
+	d := NewDecoder(e.Data())
+	o, err := d.DecodeTime()
+	if err != nil {
+		t.Fatalf("Error decoding type: %s\n", err)
+	}
+
+	if !o.Equal(i) {
+		t.Fatalf("Expected output %v to match input %v.\n", o, i)
+	}
+
+	if o.IsZero() != i.IsZero() {
+		t.Fatalf("Expected output zero %t to match input zero %t.\n", o.IsZero(), i.IsZero())
+	}
+
+	in, io := i.Zone()
+	on, oo := o.Zone()
+	if in != on || io != oo {
+		t.Fatalf("Expected output zone %s%+d to match input zone %s%+d.\n", on, oo, in, io)
+	}
+
+	if i.Location() != time.Local && o.Location().String() != i.Location().String() {
+		t.Fatalf("Expected output location %s to match input location %s.\n", o.Location(), i.Location())
+	}
+}
+
+// mustField begins field n on the encoder and fails the test on error.
+func mustField(e *Encoder, n int, t *testing.T) {
+	if err := e.Field(n); err != nil {
+		t.Fatalf("Unable to begin field %d: %s\n", n, err)
+	}
+}
+
 func testCompressDecompress(s string, t *testing.T) {
 	e := NewEncoder()
 	e.EncodeString(s)
@@ -0,0 +1,105 @@
+package coding
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Decimal types are fixed-point decimal numbers.
+//
+// A decimal's value is its unscaled integer multiplied by ten to the power of
+// its negated scale, so the decimal with unscaled integer 123450 and scale 4 is
+// 12.3450. Trailing zeros are kept.
+type Decimal struct {
+
+	// The decimal's unscaled integer.
+	unscaled int64
+
+	// The number of digits after the decimal's decimal point.
+	scale int32
+}
+
+// Initializers
+
+// NewDecimal creates a new decimal with the given unscaled integer and scale.
+func NewDecimal(unscaled int64, scale int32) Decimal {
+	return Decimal{
+		unscaled: unscaled,
+		scale:    scale,
+	}
+}
+
+// ParseDecimal parses a decimal from a string such as "12.3450" or "-0.5".
+//
+// The decimal's scale is the number of digits after the decimal point in s.
+func ParseDecimal(s string) (Decimal, error) {
+	digits := s
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		digits = digits[1:]
+	}
+
+	var scale int32
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		scale = int32(len(digits) - i - 1)
+		digits = digits[:i] + digits[i+1:]
+	}
+
+	if len(digits) == 0 {
+		return Decimal{}, ErrDecimal
+	}
+
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return Decimal{}, ErrDecimal
+		}
+	}
+
+	if s[0] == '-' {
+		digits = "-" + digits
+	}
+
+	unscaled, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Decimal{}, ErrDecimal
+	}
+
+	return NewDecimal(unscaled, scale), nil
+}
+
+// Exported methods
+
+// Unscaled returns the decimal's unscaled integer.
+func (x Decimal) Unscaled() int64 {
+	return x.unscaled
+}
+
+// Scale returns the number of digits after the decimal's decimal point.
+func (x Decimal) Scale() int32 {
+	return x.scale
+}
+
+// String returns the decimal formatted with exactly Scale digits after its
+// decimal point.
+func (x Decimal) String() string {
+	digits := strconv.FormatInt(x.unscaled, 10)
+
+	sign := ""
+	if x.unscaled < 0 {
+		sign = "-"
+		digits = digits[1:]
+	}
+
+	if x.scale <= 0 {
+		if x.unscaled == 0 {
+			return "0"
+		}
+		return sign + digits + strings.Repeat("0", int(-x.scale))
+	}
+
+	scale := int(x.scale)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
@@ -7,6 +7,12 @@ import (
 	"errors"
 	"hash/crc32"
 	"math"
+	"math/big"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"time"
+	"unicode/utf8"
 )
 
 var (
@@ -21,18 +27,119 @@ var (
 
 	// ErrCRC is a CRC check error.
 	ErrCRC = errors.New("crc check failed")
+
+	// ErrValue is an invalid value error.
+	ErrValue = errors.New("invalid value")
+
+	// ErrDecimal is an invalid decimal error.
+	ErrDecimal = errors.New("invalid decimal")
+
+	// ErrTensor is an invalid tensor error.
+	ErrTensor = errors.New("invalid tensor")
+
+	// ErrSliceLength is a slice too short error.
+	ErrSliceLength = errors.New("slice too short")
+
+	// ErrSparseVector is an invalid sparse vector error.
+	ErrSparseVector = errors.New("invalid sparse vector")
+
+	// ErrUnregistered is an unregistered type error.
+	ErrUnregistered = errors.New("unregistered type")
+
+	// ErrTooLarge is a value too large error.
+	ErrTooLarge = errors.New("value too large")
+
+	// ErrStringRef is an unknown string reference error.
+	ErrStringRef = errors.New("unknown string reference")
+
+	// ErrUUID is an invalid UUID error.
+	ErrUUID = errors.New("invalid uuid")
+
+	// ErrUTF8 is an invalid UTF-8 error.
+	ErrUTF8 = errors.New("invalid utf-8")
+
+	// ErrSet is an invalid set error.
+	ErrSet = errors.New("invalid set")
+
+	// ErrMap is an invalid map error.
+	ErrMap = errors.New("invalid map")
+
+	// ErrJSON is an invalid JSON error.
+	ErrJSON = errors.New("invalid json")
+
+	// ErrEnum is an unknown enum value error.
+	ErrEnum = errors.New("unknown enum value")
+
+	// ErrReference is an unknown value reference error.
+	ErrReference = errors.New("unknown reference")
+
+	// ErrNoIndex is a missing index error.
+	ErrNoIndex = errors.New("no index")
+
+	// ErrIndex is an invalid index error.
+	ErrIndex = errors.New("invalid index")
+
+	// ErrIndexRange is an index out of range error.
+	ErrIndexRange = errors.New("index out of range")
+
+	// ErrNoSection is a missing section error.
+	ErrNoSection = errors.New("no open section")
+
+	// ErrNoRecord is a missing record error.
+	ErrNoRecord = errors.New("no open record")
+
+	// ErrNoField is a missing record field error.
+	ErrNoField = errors.New("no open field")
+
+	// ErrFieldNumber is an invalid field number error.
+	ErrFieldNumber = errors.New("invalid field number")
+
+	// ErrMissingField is a missing field error.
+	ErrMissingField = errors.New("missing field")
 )
 
 // Decoder types decode bytes and keep track of an offset.
 type Decoder struct {
 	data   []byte
 	offset int
+
+	// The bounds of the decoder's values in data.
+	start int
+	end   int
+
+	// The offset of the data's index table, or zero if the data has no index.
+	tableOffset int
+
+	// Whether or not the decoder's bounds have been loaded from its data.
+	loaded bool
+
+	// The strings in the data's string table that have been decoded so far,
+	// which the decoder shares with its sub-decoders.
+	strings *definitions
+
+	// The pointers that have been decoded by DecodeAny so far, which the
+	// decoder shares with its sub-decoders so that references in different
+	// sections decode to the same pointers.
+	refs *definitions
+
+	// The ordinals of the value definitions that ToJSON has written so far,
+	// keyed by the offsets of the definitions.
+	jsonDefs map[int]int
+
+	// Whether or not the decoder rejects strings that aren't valid UTF-8.
+	strictUTF8 bool
+
+	// The maximum number of elements that the decoder expands a value to.
+	maxElements int
 }
 
 // NewDecoder creates and returns a new decoder with the given data.
 func NewDecoder(data []byte) *Decoder {
 	return &Decoder{
-		data: data,
+		data:        data,
+		strings:     newDefinitions(),
+		refs:        newDefinitions(),
+		maxElements: defaultMaxElements,
 	}
 }
 
@@ -52,6 +159,83 @@ func (d *Decoder) DecodeBool() (bool, error) {
 	return bb == 1, nil
 }
 
+// DecodeBools decodes the next value as a bitset of booleans.
+func (d *Decoder) DecodeBools() ([]bool, error) {
+	b, err := d.DecodeBitset()
+	if err != nil {
+		return nil, err
+	}
+	return b.Bools(), nil
+}
+
+// DecodeBitset decodes the next value as a bitset.
+func (d *Decoder) DecodeBitset() (*Bitset, error) {
+	if err := d.checkType(codingTypeBitset); err != nil {
+		return nil, err
+	}
+
+	n, err := d.decodeUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	if !d.checkLength(1) {
+		return nil, ErrEOB
+	}
+
+	switch d.getByte() {
+	case bitsetPacked:
+		if n > uint64(8*(d.end-d.offset)) {
+			return nil, ErrEOB
+		}
+
+		b := NewBitset(int(n))
+		copy(b.bits, d.getBytes(len(b.bits)))
+
+		// Bits past the end of the bitset must be cleared
+		if n%8 != 0 && b.bits[len(b.bits)-1]>>(n%8) != 0 {
+			return nil, ErrValue
+		}
+		return b, nil
+	case bitsetRunLength:
+		if n > uint64(d.maxElements) {
+			return nil, ErrTooLarge
+		}
+
+		c, err := d.getElementCount(1)
+		if err != nil {
+			return nil, err
+		}
+
+		b := NewBitset(int(n))
+		i := uint64(0)
+		for j := 0; j < c; j++ {
+			r, err := d.decodeUvarint()
+			if err != nil {
+				return nil, err
+			}
+
+			if r > n-i {
+				return nil, ErrValue
+			}
+
+			if j%2 == 1 {
+				for k := i; k < i+r; k++ {
+					b.Set(int(k))
+				}
+			}
+			i += r
+		}
+
+		if i != n {
+			return nil, ErrValue
+		}
+		return b, nil
+	default:
+		return nil, ErrValue
+	}
+}
+
 // Integer
 
 // DecodeInt decodes the next value as an integer.
@@ -244,160 +428,2203 @@ func (d *Decoder) DecodeFloat32() (float32, error) {
 	return math.Float32frombits(uint32(i)), nil
 }
 
-// Data
+// Half precision floating point
 
-// DecodeString decodes the next value as a string.
-func (d *Decoder) DecodeString() (string, error) {
-	if err := d.checkType(codingTypeString); err != nil {
-		return "", err
+// DecodeFloat16 decodes the next value as an IEEE 754 half precision floating
+// point number.
+func (d *Decoder) DecodeFloat16() (float32, error) {
+	if err := d.checkType(codingTypeFloat16); err != nil {
+		return 0, err
 	}
 
-	if !d.checkLength(8) {
-		return "", ErrEOB
+	if !d.checkLength(2) {
+		return 0, ErrEOB
 	}
 
-	l, err := d.decodeInt64(8)
+	return float16ToFloat32(binary.LittleEndian.Uint16(d.getBytes(2))), nil
+}
+
+// DecodeBFloat16 decodes the next value as a bfloat16 floating point number.
+func (d *Decoder) DecodeBFloat16() (float32, error) {
+	if err := d.checkType(codingTypeBFloat16); err != nil {
+		return 0, err
+	}
+
+	if !d.checkLength(2) {
+		return 0, ErrEOB
+	}
+
+	return bfloat16ToFloat32(binary.LittleEndian.Uint16(d.getBytes(2))), nil
+}
+
+// DecodeFloat16s decodes the next value as a slice of IEEE 754 half precision
+// floating point numbers.
+func (d *Decoder) DecodeFloat16s() ([]float32, error) {
+	if err := d.checkType(codingTypeFloat16Slice); err != nil {
+		return nil, err
+	}
+
+	b, err := d.getElementBytes(2)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if !d.checkLength(int(l)) {
-		return "", ErrEOB
+	fs := make([]float32, len(b)/2)
+	for i := range fs {
+		fs[i] = float16ToFloat32(binary.LittleEndian.Uint16(b[2*i:]))
 	}
+	return fs, nil
+}
 
-	if l == 0 {
-		return "", nil
+// DecodeBFloat16s decodes the next value as a slice of bfloat16 floating point
+// numbers.
+func (d *Decoder) DecodeBFloat16s() ([]float32, error) {
+	if err := d.checkType(codingTypeBFloat16Slice); err != nil {
+		return nil, err
 	}
-	return string(d.getBytes(int(l))), nil
+
+	b, err := d.getElementBytes(2)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := make([]float32, len(b)/2)
+	for i := range fs {
+		fs[i] = bfloat16ToFloat32(binary.LittleEndian.Uint16(b[2*i:]))
+	}
+	return fs, nil
 }
 
-// DecodeData decodes the next value as a byte array.
-func (d *Decoder) DecodeData() ([]byte, error) {
-	if err := d.checkType(codingTypeData); err != nil {
+// Packed slice
+
+// DecodeFloat64s decodes the next value as a packed slice of floats.
+func (d *Decoder) DecodeFloat64s() ([]float64, error) {
+	s, err := d.decodeSlice(codingTypeFloat64)
+	if err != nil {
 		return nil, err
 	}
+	return s.([]float64), nil
+}
 
-	l, err := d.decodeInt64(8)
+// DecodeFloat32s decodes the next value as a packed slice of floats.
+func (d *Decoder) DecodeFloat32s() ([]float32, error) {
+	s, err := d.decodeSlice(codingTypeFloat32)
 	if err != nil {
 		return nil, err
 	}
+	return s.([]float32), nil
+}
 
-	if !d.checkLength(int(l)) {
-		return nil, ErrEOB
+// DecodeInt64s decodes the next value as a packed slice of integers.
+func (d *Decoder) DecodeInt64s() ([]int64, error) {
+	s, err := d.decodeSlice(codingTypeInt64)
+	if err != nil {
+		return nil, err
 	}
+	return s.([]int64), nil
+}
 
-	if l == 0 {
-		return nil, nil
+// DecodeInt32s decodes the next value as a packed slice of integers.
+func (d *Decoder) DecodeInt32s() ([]int32, error) {
+	s, err := d.decodeSlice(codingTypeInt32)
+	if err != nil {
+		return nil, err
 	}
-	return d.getBytes(int(l)), nil
+	return s.([]int32), nil
 }
 
-// Exported methods
+// DecodeInt16s decodes the next value as a packed slice of integers.
+func (d *Decoder) DecodeInt16s() ([]int16, error) {
+	s, err := d.decodeSlice(codingTypeInt16)
+	if err != nil {
+		return nil, err
+	}
+	return s.([]int16), nil
+}
 
-// Decompress decompresses the decoder's data and places the result in data.
-func (d *Decoder) Decompress() error {
-	b := bytes.NewBuffer(d.data)
-	r, err := zlib.NewReader(b)
+// DecodeInt8s decodes the next value as a packed slice of integers.
+func (d *Decoder) DecodeInt8s() ([]int8, error) {
+	s, err := d.decodeSlice(codingTypeInt8)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return s.([]int8), nil
+}
 
-	ob := new(bytes.Buffer)
-	if _, err := ob.ReadFrom(r); err != nil {
-		return err
+// DecodeUint64s decodes the next value as a packed slice of integers.
+func (d *Decoder) DecodeUint64s() ([]uint64, error) {
+	s, err := d.decodeSlice(codingTypeUint64)
+	if err != nil {
+		return nil, err
 	}
+	return s.([]uint64), nil
+}
 
-	if err := r.Close(); err != nil {
-		return err
+// DecodeUint32s decodes the next value as a packed slice of integers.
+func (d *Decoder) DecodeUint32s() ([]uint32, error) {
+	s, err := d.decodeSlice(codingTypeUint32)
+	if err != nil {
+		return nil, err
 	}
+	return s.([]uint32), nil
+}
 
-	d.data = ob.Bytes()
-	return nil
+// DecodeUint16s decodes the next value as a packed slice of integers.
+func (d *Decoder) DecodeUint16s() ([]uint16, error) {
+	s, err := d.decodeSlice(codingTypeUint16)
+	if err != nil {
+		return nil, err
+	}
+	return s.([]uint16), nil
 }
 
-// Validate validates the decoder's data by calculating its CRC32 and comparing.
-func (d Decoder) Validate() error {
-	if len(d.data) < 1 {
-		return ErrByteLength
+// DecodeUint8s decodes the next value as a packed slice of integers.
+func (d *Decoder) DecodeUint8s() ([]uint8, error) {
+	s, err := d.decodeSlice(codingTypeUint8)
+	if err != nil {
+		return nil, err
 	}
+	return s.([]uint8), nil
+}
 
-	l := int(d.data[len(d.data)-1:][0])
+// DecodeSliceInto decodes the next value as a packed slice in to s, which must
+// be a slice of a fixed-width numeric type, and returns the number of elements
+// decoded.
+//
+// If s is too short to hold the slice's elements, then ErrSliceLength is
+// returned and the decoder's offset is not changed. Call SliceLen to get the
+// number of elements in the next packed slice.
+func (d *Decoder) DecodeSliceInto(s interface{}) (int, error) {
+	kind, l, ok := elementKind(s)
+	if !ok {
+		return 0, ErrType
+	}
 
-	r := bytes.NewReader(d.data[len(d.data)-l-1 : len(d.data)-1])
-	i, err := binary.ReadUvarint(r)
+	if err := d.load(); err != nil {
+		return 0, err
+	}
+
+	start := d.offset
+	n, runLength, err := d.checkSliceType(kind)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	crc := uint32(i)
-	ccrc := crc32.ChecksumIEEE(d.data[:len(d.data)-l-1])
-	if crc != ccrc {
-		return ErrCRC
+	if n > l {
+		d.offset = start
+		return 0, ErrSliceLength
 	}
-	return nil
+
+	if err := d.getSliceElements(reflect.ValueOf(s).Slice(0, n).Interface(), runLength); err != nil {
+		return 0, err
+	}
+	return n, nil
 }
 
-// Non-exported methods
+// SliceLen returns the number of elements in the next value, which must be a
+// packed slice, without decoding it.
+func (d *Decoder) SliceLen() (int, error) {
+	if err := d.load(); err != nil {
+		return 0, err
+	}
 
-// checkType checks the given type against the next type byte in the decoder's
-// data.
+	start := d.offset
+	defer func() {
+		d.offset = start
+	}()
+
+	_, n, _, err := d.checkSliceHeader()
+	return n, err
+}
+
+// Array
+
+// DecodeArray decodes the next value as a fixed-size array in to the array that
+// a points to.
 //
-// If the type given matches the type byte, then the current byte offset is
-// increased by one, otherwise it is not changed.
-func (d *Decoder) checkType(t byte) error {
-	// Can we get the type byte?
+// ErrType is returned, and the decoder's offset is not changed, if the value's
+// element type or length doesn't match the array's.
+func (d *Decoder) DecodeArray(a interface{}) error {
+	if reflect.ValueOf(a).Kind() != reflect.Ptr {
+		return ErrType
+	}
+
+	s, kind, ok := arrayElements(a)
+	if !ok {
+		return ErrType
+	}
+
+	if err := d.load(); err != nil {
+		return err
+	}
+
+	start := d.offset
+	if err := d.checkType(codingTypeArray); err != nil {
+		return err
+	}
+
 	if !d.checkLength(1) {
+		d.offset = start
 		return ErrEOB
 	}
 
-	// Get it and check
-	tb := d.getByte()
-	if tb == t {
-		return nil
+	k := d.getByte()
+	n, err := d.decodeUvarint()
+	if err != nil {
+		d.offset = start
+		return err
 	}
 
-	// Since they weren't the same, undo the offset change from getByte
-	d.decrementOffset(1)
-	return ErrType
+	_, l, _ := elementKind(s)
+	if k != kind || n != uint64(l) {
+		d.offset = start
+		return ErrType
+	}
+
+	if !d.checkLength(l * elementSize(kind)) {
+		d.offset = start
+		return ErrEOB
+	}
+
+	unpackElements(s, d.getBytes(l*elementSize(kind)))
+	return nil
 }
 
-// decodeInt64 decodes the next byteLength bytes in to an int64 value.
-func (d *Decoder) decodeInt64(byteLength int) (int64, error) {
-	r, err := d.getIntByteReader(byteLength)
+// Sparse vector
+
+// DecodeSparseVector decodes the next value as a sparse vector.
+func (d *Decoder) DecodeSparseVector() (*SparseVector, error) {
+	if err := d.checkType(codingTypeSparseVector); err != nil {
+		return nil, err
+	}
+
+	if !d.checkLength(1) {
+		return nil, ErrEOB
+	}
+
+	kind := d.getByte()
+	if kind != codingTypeFloat32 && kind != codingTypeFloat64 {
+		return nil, ErrSparseVector
+	}
+	size := elementSize(kind)
+
+	length, err := d.decodeUvarint()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return binary.ReadVarint(r)
+
+	// Sparse vectors are decoded without their zeros, but their dense slices
+	// hold every element
+	if length > uint64(d.maxElements) {
+		return nil, ErrTooLarge
+	}
+
+	// Every element takes at least one byte for its index and size bytes for
+	// its value
+	n, err := d.getElementCount(size + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	indices := make([]int, n)
+	prev := uint64(0)
+	for i := range indices {
+		delta, err := d.decodeUvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		if (i > 0 && delta == 0) || delta >= length-prev {
+			return nil, ErrSparseVector
+		}
+
+		prev += delta
+		indices[i] = int(prev)
+	}
+
+	if !d.checkLength(n * size) {
+		return nil, ErrEOB
+	}
+
+	values := makeElements(kind, n)
+	unpackElements(values, d.getBytes(n*size))
+
+	return &SparseVector{
+		length:  int(length),
+		indices: indices,
+		values:  values,
+	}, nil
 }
 
-// decodeUint64 decodes the next byteLength bytes in to an uint64 value.
-func (d *Decoder) decodeUint64(byteLength int) (uint64, error) {
-	r, err := d.getIntByteReader(byteLength)
+// DecodeSparseFloat32s decodes the next value as a sparse vector and returns it
+// as a dense slice.
+func (d *Decoder) DecodeSparseFloat32s() ([]float32, error) {
+	v, err := d.DecodeSparseVector()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return binary.ReadUvarint(r)
+	return v.Float32s(), nil
 }
 
-// getIntByteReader creates a new byte reader with the next byteLength bytes.
-func (d *Decoder) getIntByteReader(byteLength int) (*bytes.Reader, error) {
-	if !d.checkLength(byteLength) {
+// DecodeSparseFloat64s decodes the next value as a sparse vector and returns it
+// as a dense slice.
+func (d *Decoder) DecodeSparseFloat64s() ([]float64, error) {
+	v, err := d.DecodeSparseVector()
+	if err != nil {
+		return nil, err
+	}
+	return v.Float64s(), nil
+}
+
+// Series
+
+// DecodeInt64Series decodes the next value as a series of integers.
+func (d *Decoder) DecodeInt64Series() ([]int64, error) {
+	if err := d.checkType(codingTypeInt64Series); err != nil {
+		return nil, err
+	}
+
+	if !d.checkLength(1) {
 		return nil, ErrEOB
 	}
 
-	ib := d.getBytes(byteLength)
-	return bytes.NewReader(ib), nil
-}
+	mode := d.getByte()
+	if mode != seriesDelta && mode != seriesDeltaOfDelta {
+		return nil, ErrValue
+	}
 
-// checkLength checks that there are enough bytes in the buffer from the
-// decoder's offset to satisfy the given length.
-func (d *Decoder) checkLength(l int) bool {
-	if len(d.data) < 1 {
-		return false
+	n, err := d.getElementCount(1)
+	if err != nil {
+		return nil, err
 	}
 
-	crcLength := int(d.data[len(d.data)-1])
-	return d.offset+l-1 < len(d.data)-crcLength-1
+	s := make([]int64, n)
+	if n == 0 {
+		return s, nil
+	}
+
+	var delta int64
+	if mode == seriesDeltaOfDelta && n > 1 {
+		if s[0], err = d.decodeVarint(); err != nil {
+			return nil, err
+		}
+
+		if delta, err = d.decodeVarint(); err != nil {
+			return nil, err
+		}
+
+		s[1] = s[0] + delta
+		for i := 2; i < n; i++ {
+			dod, err := d.decodeVarint()
+			if err != nil {
+				return nil, err
+			}
+
+			delta += dod
+			s[i] = s[i-1] + delta
+		}
+		return s, nil
+	}
+
+	for i := range s {
+		if delta, err = d.decodeVarint(); err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			s[i] = delta
+		} else {
+			s[i] = s[i-1] + delta
+		}
+	}
+	return s, nil
+}
+
+// DecodeFloat64Series decodes the next value as a Gorilla XOR compressed
+// series of floats.
+func (d *Decoder) DecodeFloat64Series() ([]float64, error) {
+	if err := d.checkType(codingTypeFloat64Series); err != nil {
+		return nil, err
+	}
+
+	n, err := d.decodeUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := d.getLengthBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every value after the first takes at least one bit
+	if n > 0 && n-1 > uint64(8*len(b)) {
+		return nil, ErrValue
+	}
+
+	r := &bitReader{b: b}
+	s := make([]float64, n)
+
+	var prev uint64
+	leading, trailing := -1, 0
+	for i := range s {
+		if i == 0 {
+			if prev, err = r.readBits(64); err != nil {
+				return nil, err
+			}
+			s[i] = math.Float64frombits(prev)
+			continue
+		}
+
+		changed, err := r.readBit()
+		if err != nil {
+			return nil, err
+		}
+
+		if changed {
+			newWindow, err := r.readBit()
+			if err != nil {
+				return nil, err
+			}
+
+			if newWindow {
+				l, err := r.readBits(5)
+				if err != nil {
+					return nil, err
+				}
+
+				m, err := r.readBits(6)
+				if err != nil {
+					return nil, err
+				}
+
+				if m == 0 {
+					m = 64
+				}
+
+				if int(l+m) > 64 {
+					return nil, ErrValue
+				}
+				leading, trailing = int(l), 64-int(l+m)
+			} else if leading < 0 {
+				return nil, ErrValue
+			}
+
+			x, err := r.readBits(64 - leading - trailing)
+			if err != nil {
+				return nil, err
+			}
+			prev ^= x << uint(trailing)
+		}
+
+		s[i] = math.Float64frombits(prev)
+	}
+
+	return s, nil
+}
+
+// Tensor
+
+// DecodeTensor decodes the next value as a tensor.
+func (d *Decoder) DecodeTensor() (*Tensor, error) {
+	if err := d.checkType(codingTypeTensor); err != nil {
+		return nil, err
+	}
+
+	if !d.checkLength(1) {
+		return nil, ErrEOB
+	}
+
+	kind := d.getByte()
+	size := elementSize(kind)
+	if size == 0 {
+		return nil, ErrTensor
+	}
+
+	rank, err := d.decodeUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every dimension takes at least one byte
+	if rank > uint64(d.end-d.offset) {
+		return nil, ErrEOB
+	}
+
+	shape := make([]int, rank)
+	for i := range shape {
+		s, err := d.decodeUvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		if s > math.MaxInt32 {
+			return nil, ErrTensor
+		}
+		shape[i] = int(s)
+	}
+
+	n, ok := shapeLength(shape)
+	if !ok {
+		return nil, ErrTensor
+	}
+
+	if n > (d.end-d.offset)/size {
+		return nil, ErrEOB
+	}
+
+	data := makeElements(kind, n)
+	unpackElements(data, d.getBytes(n*size))
+
+	return &Tensor{
+		shape: shape,
+		data:  data,
+	}, nil
+}
+
+// Complex
+
+// DecodeComplex128 decodes the next value as a complex number.
+func (d *Decoder) DecodeComplex128() (complex128, error) {
+	if err := d.checkType(codingTypeComplex128); err != nil {
+		return 0, err
+	}
+
+	r, err := d.decodeBits()
+	if err != nil {
+		return 0, err
+	}
+
+	i, err := d.decodeBits()
+	if err != nil {
+		return 0, err
+	}
+
+	return complex(math.Float64frombits(r), math.Float64frombits(i)), nil
+}
+
+// DecodeComplex64 decodes the next value as a complex number.
+func (d *Decoder) DecodeComplex64() (complex64, error) {
+	if err := d.checkType(codingTypeComplex64); err != nil {
+		return 0, err
+	}
+
+	r, err := d.decodeBits()
+	if err != nil {
+		return 0, err
+	}
+
+	i, err := d.decodeBits()
+	if err != nil {
+		return 0, err
+	}
+
+	return complex(math.Float32frombits(uint32(r)), math.Float32frombits(uint32(i))), nil
+}
+
+// 128-bit integer
+
+// DecodeInt128 decodes the next value as an integer.
+func (d *Decoder) DecodeInt128() (Int128, error) {
+	if err := d.checkType(codingTypeInt128); err != nil {
+		return Int128{}, err
+	}
+
+	hi, err := d.decodeBits()
+	if err != nil {
+		return Int128{}, err
+	}
+
+	lo, err := d.decodeBits()
+	if err != nil {
+		return Int128{}, err
+	}
+
+	return Int128{Hi: int64(hi), Lo: lo}, nil
+}
+
+// DecodeUint128 decodes the next value as an integer.
+func (d *Decoder) DecodeUint128() (Uint128, error) {
+	if err := d.checkType(codingTypeUint128); err != nil {
+		return Uint128{}, err
+	}
+
+	hi, err := d.decodeBits()
+	if err != nil {
+		return Uint128{}, err
+	}
+
+	lo, err := d.decodeBits()
+	if err != nil {
+		return Uint128{}, err
+	}
+
+	return Uint128{Hi: hi, Lo: lo}, nil
+}
+
+// Data
+
+// DecodeString decodes the next value as a string.
+//
+// Strings encoded with a string table are decoded transparently. If the decoder
+// is strict and the string isn't valid UTF-8, then ErrUTF8 is returned.
+func (d *Decoder) DecodeString() (string, error) {
+	s, err := d.decodeString()
+	if err != nil {
+		return "", err
+	}
+
+	if d.strictUTF8 && !utf8.ValidString(s) {
+		return "", ErrUTF8
+	}
+	return s, nil
+}
+
+// DecodeRunes decodes the next value as a string and returns its runes.
+//
+// Invalid UTF-8 is decoded as the replacement character, unless the decoder is
+// strict, in which case ErrUTF8 is returned.
+func (d *Decoder) DecodeRunes() ([]rune, error) {
+	s, err := d.DecodeString()
+	if err != nil {
+		return nil, err
+	}
+	return []rune(s), nil
+}
+
+// decodeString decodes the next value as a string without validating it.
+func (d *Decoder) decodeString() (string, error) {
+	if s, ok, err := d.decodeTableString(); ok || err != nil {
+		return s, err
+	}
+
+	if err := d.checkType(codingTypeString); err != nil {
+		return "", err
+	}
+
+	if !d.checkLength(8) {
+		return "", ErrEOB
+	}
+
+	l, err := d.decodeInt64(8)
+	if err != nil {
+		return "", err
+	}
+
+	if !d.checkLength(int(l)) {
+		return "", ErrEOB
+	}
+
+	if l == 0 {
+		return "", nil
+	}
+	return string(d.getBytes(int(l))), nil
+}
+
+// DecodeData decodes the next value as a byte array.
+func (d *Decoder) DecodeData() ([]byte, error) {
+	if err := d.checkType(codingTypeData); err != nil {
+		return nil, err
+	}
+
+	l, err := d.decodeInt64(8)
+	if err != nil {
+		return nil, err
+	}
+
+	if !d.checkLength(int(l)) {
+		return nil, ErrEOB
+	}
+
+	if l == 0 {
+		return nil, nil
+	}
+	return d.getBytes(int(l)), nil
+}
+
+// Arbitrary precision
+
+// DecodeBigInt decodes the next value as an arbitrary precision integer.
+func (d *Decoder) DecodeBigInt() (*big.Int, error) {
+	if err := d.checkType(codingTypeBigInt); err != nil {
+		return nil, err
+	}
+	return d.getBigInt()
+}
+
+// DecodeBigRat decodes the next value as an arbitrary precision rational
+// number.
+func (d *Decoder) DecodeBigRat() (*big.Rat, error) {
+	if err := d.checkType(codingTypeBigRat); err != nil {
+		return nil, err
+	}
+
+	a, err := d.getBigInt()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := d.getBigInt()
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Sign() <= 0 {
+		return nil, ErrValue
+	}
+
+	return new(big.Rat).SetFrac(a, b), nil
+}
+
+// DecodeBigFloat decodes the next value as an arbitrary precision floating
+// point number with its encoded precision and rounding mode.
+func (d *Decoder) DecodeBigFloat() (*big.Float, error) {
+	if err := d.checkType(codingTypeBigFloat); err != nil {
+		return nil, err
+	}
+
+	b, err := d.getLengthBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	x := new(big.Float)
+	if err := x.GobDecode(b); err != nil {
+		return nil, ErrValue
+	}
+	return x, nil
+}
+
+// Decimal
+
+// DecodeDecimal decodes the next value as a fixed-point decimal.
+func (d *Decoder) DecodeDecimal() (Decimal, error) {
+	if err := d.checkType(codingTypeDecimal); err != nil {
+		return Decimal{}, err
+	}
+
+	unscaled, err := d.decodeVarint()
+	if err != nil {
+		return Decimal{}, err
+	}
+
+	scale, err := d.decodeVarint()
+	if err != nil {
+		return Decimal{}, err
+	}
+
+	if scale < math.MinInt32 || scale > math.MaxInt32 {
+		return Decimal{}, ErrValue
+	}
+
+	return NewDecimal(unscaled, int32(scale)), nil
+}
+
+// Time
+
+// DecodeTime decodes the next value as a time.
+//
+// If the time was encoded in a named location that can't be loaded, then the
+// time is returned in a fixed zone with the location's name and offset.
+func (d *Decoder) DecodeTime() (time.Time, error) {
+	if err := d.checkType(codingTypeTime); err != nil {
+		return time.Time{}, err
+	}
+
+	sec, err := d.decodeVarint()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	nsec, err := d.decodeUvarint()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if nsec >= uint64(time.Second) {
+		return time.Time{}, ErrValue
+	}
+
+	if !d.checkLength(1) {
+		return time.Time{}, ErrEOB
+	}
+
+	t := time.Unix(sec, int64(nsec))
+	zone := d.getByte()
+	if zone == timeZoneUTC {
+		return t.UTC(), nil
+	}
+
+	if zone != timeZoneOffset && zone != timeZoneLocation {
+		return time.Time{}, ErrValue
+	}
+
+	offset, err := d.decodeVarint()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	name, err := d.getString()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if zone == timeZoneLocation {
+		if l, err := time.LoadLocation(name); err == nil {
+			return t.In(l), nil
+		}
+	}
+	return t.In(time.FixedZone(name, int(offset))), nil
+}
+
+// DecodeDuration decodes the next value as a duration.
+func (d *Decoder) DecodeDuration() (time.Duration, error) {
+	if err := d.checkType(codingTypeDuration); err != nil {
+		return 0, err
+	}
+
+	n, err := d.decodeVarint()
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(n), nil
+}
+
+// Identifier
+
+// DecodeUUID decodes the next value as a UUID.
+func (d *Decoder) DecodeUUID() (UUID, error) {
+	if err := d.checkType(codingTypeUUID); err != nil {
+		return UUID{}, err
+	}
+
+	if !d.checkLength(16) {
+		return UUID{}, ErrEOB
+	}
+
+	var u UUID
+	copy(u[:], d.getBytes(16))
+	return u, nil
+}
+
+// Network
+
+// DecodeAddr decodes the next value as an IP address.
+//
+// ErrValue is returned if the address's length or zone is invalid.
+func (d *Decoder) DecodeAddr() (netip.Addr, error) {
+	if err := d.checkType(codingTypeAddr); err != nil {
+		return netip.Addr{}, err
+	}
+	return d.getAddr()
+}
+
+// DecodePrefix decodes the next value as an IP prefix.
+//
+// ErrValue is returned if the prefix's address or number of bits is invalid.
+func (d *Decoder) DecodePrefix() (netip.Prefix, error) {
+	if err := d.checkType(codingTypePrefix); err != nil {
+		return netip.Prefix{}, err
+	}
+
+	a, err := d.getAddr()
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	bits, err := d.decodeVarint()
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	if !a.IsValid() {
+		if bits != -1 {
+			return netip.Prefix{}, ErrValue
+		}
+		return netip.Prefix{}, nil
+	}
+
+	if bits < 0 || bits > int64(a.BitLen()) {
+		return netip.Prefix{}, ErrValue
+	}
+
+	p := netip.PrefixFrom(a, int(bits))
+	if !p.IsValid() {
+		return netip.Prefix{}, ErrValue
+	}
+	return p, nil
+}
+
+// DecodeAddrPort decodes the next value as an IP address and port.
+//
+// ErrValue is returned if the address is invalid, or if the zero address has a
+// port.
+func (d *Decoder) DecodeAddrPort() (netip.AddrPort, error) {
+	if err := d.checkType(codingTypeAddrPort); err != nil {
+		return netip.AddrPort{}, err
+	}
+
+	a, err := d.getAddr()
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+
+	if !d.checkLength(2) {
+		return netip.AddrPort{}, ErrEOB
+	}
+
+	port := binary.LittleEndian.Uint16(d.getBytes(2))
+	if !a.IsValid() && port != 0 {
+		return netip.AddrPort{}, ErrValue
+	}
+	return netip.AddrPortFrom(a, port), nil
+}
+
+// DecodeURL decodes the next value as a URL.
+//
+// A nil value is decoded as a nil URL, and ErrValue is returned if the URL
+// can't be parsed.
+func (d *Decoder) DecodeURL() (*url.URL, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	if err := d.checkType(codingTypeURL); err != nil {
+		return nil, err
+	}
+
+	s, err := d.getString()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, ErrValue
+	}
+	return u, nil
+}
+
+// Nil
+
+// DecodeNil decodes the next value if it is nil.
+//
+// If the next value is nil, then it is decoded and true is returned, otherwise
+// false is returned and the decoder's offset is not changed.
+func (d *Decoder) DecodeNil() (bool, error) {
+	switch err := d.checkType(codingTypeNil); err {
+	case nil:
+		return true, nil
+	case ErrType:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// DecodeNullableBool decodes the next value as a boolean or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableBool() (*bool, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeBool()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableInt decodes the next value as an integer or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableInt() (*int, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeInt()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableInt64 decodes the next value as an integer or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableInt64() (*int64, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeInt64()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableInt32 decodes the next value as an integer or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableInt32() (*int32, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeInt32()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableInt16 decodes the next value as an integer or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableInt16() (*int16, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeInt16()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableInt8 decodes the next value as an integer or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableInt8() (*int8, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeInt8()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableUint decodes the next value as an integer or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableUint() (*uint, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeUint()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableUint64 decodes the next value as an integer or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableUint64() (*uint64, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeUint64()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableUint32 decodes the next value as an integer or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableUint32() (*uint32, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeUint32()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableUint16 decodes the next value as an integer or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableUint16() (*uint16, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeUint16()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableUint8 decodes the next value as an integer or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableUint8() (*uint8, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeUint8()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableFloat64 decodes the next value as a floating point number or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableFloat64() (*float64, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeFloat64()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableFloat32 decodes the next value as a floating point number or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableFloat32() (*float32, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeFloat32()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableString decodes the next value as a string or nil.
+//
+// If the next value is nil, then a nil pointer is returned.
+func (d *Decoder) DecodeNullableString() (*string, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	v, err := d.DecodeString()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// DecodeNullableData decodes the next value as a byte array or nil.
+//
+// If the next value is nil, then a nil slice is returned. Otherwise, the
+// returned slice is non-nil, even if it is empty.
+func (d *Decoder) DecodeNullableData() ([]byte, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	b, err := d.DecodeData()
+	if err != nil {
+		return nil, err
+	}
+
+	if b == nil {
+		b = []byte{}
+	}
+	return b, nil
+}
+
+// Any
+
+// DecodeAny decodes the next value as a value of a type registered with
+// Register and returns a new value of that type.
+//
+// If the value's type ID isn't registered, then ErrUnregistered is returned and
+// the decoder is moved past the value. Nil values are returned as nil.
+func (d *Decoder) DecodeAny() (interface{}, error) {
+	if isNil, err := d.DecodeNil(); isNil || err != nil {
+		return nil, err
+	}
+
+	if err := d.checkType(codingTypeAnyRef); err == nil {
+		r := d.offset - 1
+		n, err := d.decodeUvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		// The pointer's definition must come before the reference
+		if n == 0 || n > uint64(r) {
+			return nil, ErrReference
+		}
+		return d.referencedValue(r-int(n), r)
+	} else if err != ErrType {
+		return nil, err
+	}
+
+	o := d.offset
+	t := codingTypeAny
+	if err := d.checkType(codingTypeAnyDef); err == nil {
+		t = codingTypeAnyDef
+		d.decrementOffset(1)
+	} else if err != ErrType {
+		return nil, err
+	}
+
+	start, end, err := d.decodeBlock(t)
+	if err != nil {
+		return nil, err
+	}
+
+	// A pointer that has already been decoded, such as one in a record field
+	// that is decoded twice, keeps its aliasing
+	ref := -1
+	if t == codingTypeAnyDef {
+		if v, ok := d.refs.load(o); ok {
+			return v, nil
+		}
+		ref = o
+	}
+
+	ad := d.subDecoder(start, end)
+	if !ad.checkLength(4) {
+		return nil, ErrEOB
+	}
+
+	r, ok := lookupID(binary.LittleEndian.Uint32(ad.getBytes(4)))
+	if !ok {
+		return nil, ErrUnregistered
+	}
+
+	v, err := ad.decodeValue(r.t, ref)
+	if err != nil {
+		if ref >= 0 {
+			d.refs.remove(ref)
+		}
+		return nil, err
+	}
+
+	if ref >= 0 {
+		// Keep the pointer from a section that decoded the definition first
+		x, _ := d.refs.store(ref, v.Interface())
+		return x, nil
+	}
+	return v.Interface(), nil
+}
+
+// Set
+
+// DecodeSet decodes the next value as a set and returns its elements in
+// ascending order.
+//
+// The elements are returned in a slice of the type that they were encoded
+// from, such as a []int64 or a []string. ErrSet is returned if the elements
+// aren't in ascending order or contain duplicates.
+func (d *Decoder) DecodeSet() (interface{}, error) {
+	if err := d.checkType(codingTypeSet); err != nil {
+		return nil, err
+	}
+
+	if !d.checkLength(1) {
+		return nil, ErrEOB
+	}
+
+	var s interface{}
+	if kind := d.getByte(); kind == codingTypeString {
+		n, err := d.getElementCount(1)
+		if err != nil {
+			return nil, err
+		}
+
+		ss := make([]string, n)
+		for i := range ss {
+			if ss[i], err = d.getString(); err != nil {
+				return nil, err
+			}
+
+			if d.strictUTF8 && !utf8.ValidString(ss[i]) {
+				return nil, ErrUTF8
+			}
+		}
+		s = ss
+	} else {
+		size := elementSize(kind)
+		if size == 0 {
+			return nil, ErrValue
+		}
+
+		n, err := d.getElementCount(size)
+		if err != nil {
+			return nil, err
+		}
+
+		s = makeElements(kind, n)
+		unpackElements(s, d.getBytes(n*size))
+	}
+
+	if !isSortedSet(s) {
+		return nil, ErrSet
+	}
+	return s, nil
+}
+
+// Map
+
+// DecodeMap decodes the next value as a map.
+//
+// The map's keys are added in the order that they were encoded, and its values
+// are decoded with DecodeAny. ErrMap is returned if the map contains duplicate
+// keys, or if it was encoded with EncodeSortedMap and its keys aren't in
+// ascending order.
+func (d *Decoder) DecodeMap() (*OrderedMap, error) {
+	if err := d.checkType(codingTypeMap); err != nil {
+		return nil, err
+	}
+
+	if !d.checkLength(1) {
+		return nil, ErrEOB
+	}
+
+	order := d.getByte()
+	if order != mapInsertionOrder && order != mapSorted {
+		return nil, ErrValue
+	}
+
+	n, err := d.getElementCount(2)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewOrderedMap()
+	for i := 0; i < n; i++ {
+		k, err := d.getString()
+		if err != nil {
+			return nil, err
+		}
+
+		if d.strictUTF8 && !utf8.ValidString(k) {
+			return nil, ErrUTF8
+		}
+
+		if _, ok := m.values[k]; ok {
+			return nil, ErrMap
+		}
+
+		if order == mapSorted && i > 0 && k < m.keys[i-1] {
+			return nil, ErrMap
+		}
+
+		v, err := d.DecodeAny()
+		if err != nil {
+			return nil, err
+		}
+		m.Set(k, v)
+	}
+	return m, nil
+}
+
+// Enum
+
+// DecodeEnum decodes the next value as an enum and stores it in the value that
+// v points to, which must be of an integer type registered with RegisterEnum.
+//
+// ErrUnregistered is returned if v doesn't point to a registered type, and
+// ErrEnum is returned if the decoded ordinal or name isn't one of the type's
+// values.
+func (d *Decoder) DecodeEnum(v interface{}) error {
+	p := reflect.ValueOf(v)
+	if p.Kind() != reflect.Ptr || p.IsNil() {
+		return ErrUnregistered
+	}
+
+	en, ok := lookupEnum(p.Type().Elem())
+	if !ok {
+		return ErrUnregistered
+	}
+
+	if err := d.checkType(codingTypeEnum); err != nil {
+		return err
+	}
+
+	if !d.checkLength(1) {
+		return ErrEOB
+	}
+
+	var n int64
+	switch d.getByte() {
+	case enumOrdinal:
+		var err error
+		if n, err = d.decodeVarint(); err != nil {
+			return err
+		}
+
+		if _, ok := en.names[n]; !ok {
+			return ErrEnum
+		}
+	case enumName:
+		name, err := d.getString()
+		if err != nil {
+			return err
+		}
+
+		if n, ok = en.values[name]; !ok {
+			return ErrEnum
+		}
+	default:
+		return ErrValue
+	}
+
+	setEnumInt64(p.Elem(), n)
+	return nil
+}
+
+// Section
+
+// EnterSection decodes the next value as a section and returns a new decoder
+// limited to the values in the section.
+//
+// The receiver is moved past the section, and the returned decoder shares the
+// receiver's data, so sections may be decoded in parallel. Sections also share
+// the string table strings and references that have been decoded, which are
+// guarded by a lock, so a value referenced from several sections decodes to the
+// same pointer.
+func (d *Decoder) EnterSection() (*Decoder, error) {
+	start, end, err := d.decodeBlock(codingTypeSection)
+	if err != nil {
+		return nil, err
+	}
+	return d.subDecoder(start, end), nil
+}
+
+// SkipSection decodes the next value as a section and moves the decoder past
+// it without decoding any of its values.
+func (d *Decoder) SkipSection() error {
+	_, _, err := d.decodeBlock(codingTypeSection)
+	return err
+}
+
+// Record
+
+// DecodeRecord decodes the next value as a record of numbered fields.
+//
+// If a field number appears more than once in the record, then the last field
+// with the number is used.
+func (d *Decoder) DecodeRecord() (*Record, error) {
+	start, end, err := d.decodeBlock(codingTypeRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Record{
+		decoder: d.subDecoder(start, end),
+		fields:  make(map[int]recordField),
+	}
+
+	rd := d.subDecoder(start, end)
+	for rd.offset < rd.end {
+		n, err := rd.decodeUvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		if n > math.MaxInt32 {
+			return nil, ErrFieldNumber
+		}
+
+		l, err := rd.decodeInt64(8)
+		if err != nil {
+			return nil, err
+		}
+
+		if l < 0 || !rd.checkLength(int(l)) {
+			return nil, ErrEOB
+		}
+
+		r.fields[int(n)] = recordField{
+			start: rd.offset,
+			end:   rd.offset + int(l),
+		}
+		rd.incrementOffset(int(l))
+	}
+
+	return r, nil
+}
+
+// Exported methods
+
+// Decompress decompresses the decoder's data and places the result in data.
+func (d *Decoder) Decompress() error {
+	b := bytes.NewBuffer(d.data)
+	r, err := zlib.NewReader(b)
+	if err != nil {
+		return err
+	}
+
+	ob := new(bytes.Buffer)
+	if _, err := ob.ReadFrom(r); err != nil {
+		return err
+	}
+
+	if err := r.Close(); err != nil {
+		return err
+	}
+
+	d.data = ob.Bytes()
+	d.offset = 0
+	d.loaded = false
+	d.strings = newDefinitions()
+	d.refs = newDefinitions()
+	return nil
+}
+
+// SetStrictUTF8 sets whether or not the decoder rejects strings that aren't
+// valid UTF-8.
+//
+// Decoders returned by EnterSection and records use the decoder's setting at
+// the time that they are created.
+func (d *Decoder) SetStrictUTF8(enabled bool) {
+	d.strictUTF8 = enabled
+}
+
+// SetMaxElements sets the maximum number of elements that the decoder expands a
+// value to, which defaults to 16,777,216.
+//
+// Run-length encoded bitsets and slices, and sparse vectors, can hold far more
+// elements than their encoded size, so ErrTooLarge is returned for one with
+// more than n elements rather than allocating it.
+//
+// Decoders returned by EnterSection and records use the decoder's setting at
+// the time that they are created.
+func (d *Decoder) SetMaxElements(n int) {
+	d.maxElements = n
+}
+
+// Indexed returns whether or not the decoder's data contains an offset index.
+func (d *Decoder) Indexed() (bool, error) {
+	if err := d.load(); err != nil {
+		return false, err
+	}
+	return d.tableOffset > 0, nil
+}
+
+// IndexLen returns the number of values in the decoder's offset index.
+func (d *Decoder) IndexLen() (int, error) {
+	if err := d.load(); err != nil {
+		return 0, err
+	}
+
+	if d.tableOffset == 0 {
+		return 0, ErrNoIndex
+	}
+
+	n, err := d.readIndexSlot(d.tableOffset)
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// Seek moves the decoder to the i-th value in its data's offset index so that
+// the next call to a decode method decodes that value.
+func (d *Decoder) Seek(i int) error {
+	n, err := d.IndexLen()
+	if err != nil {
+		return err
+	}
+
+	if i < 0 || i >= n {
+		return ErrIndexRange
+	}
+
+	o, err := d.readIndexSlot(d.tableOffset + 8*(i+1))
+	if err != nil {
+		return err
+	}
+
+	if int(o) < d.start || int(o) >= d.end {
+		return ErrIndex
+	}
+
+	d.offset = int(o)
+	return nil
+}
+
+// At returns a new decoder positioned at the i-th value in the decoder's
+// offset index.
+//
+// The returned decoder shares the receiver's data, but has its own offset, so
+// the receiver's position is left unchanged.
+func (d *Decoder) At(i int) (*Decoder, error) {
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+
+	ad := *d
+	if err := ad.Seek(i); err != nil {
+		return nil, err
+	}
+	return &ad, nil
+}
+
+// Validate validates the decoder's data by calculating its CRC32 and comparing.
+func (d Decoder) Validate() error {
+	if len(d.data) < 1 {
+		return ErrByteLength
+	}
+
+	l := int(d.data[len(d.data)-1:][0])
+
+	r := bytes.NewReader(d.data[len(d.data)-l-1 : len(d.data)-1])
+	i, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+
+	crc := uint32(i)
+	ccrc := crc32.ChecksumIEEE(d.data[:len(d.data)-l-1])
+	if crc != ccrc {
+		return ErrCRC
+	}
+	return nil
+}
+
+// Non-exported methods
+
+// checkType checks the given type against the next type byte in the decoder's
+// data.
+//
+// If the type given matches the type byte, then the current byte offset is
+// increased by one, otherwise it is not changed.
+func (d *Decoder) checkType(t byte) error {
+	// Make sure we know where the values are
+	if err := d.load(); err != nil {
+		return err
+	}
+
+	// Can we get the type byte?
+	if !d.checkLength(1) {
+		return ErrEOB
+	}
+
+	// Get it and check
+	tb := d.getByte()
+	if tb == t {
+		return nil
+	}
+
+	// Since they weren't the same, undo the offset change from getByte
+	d.decrementOffset(1)
+	return ErrType
+}
+
+// decodeBlock decodes the next value as a length-prefixed block of type t and
+// returns the bounds of its contents.
+func (d *Decoder) decodeBlock(t byte) (int, int, error) {
+	if err := d.checkType(t); err != nil {
+		return 0, 0, err
+	}
+
+	l, err := d.decodeInt64(8)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if l < 0 || !d.checkLength(int(l)) {
+		return 0, 0, ErrEOB
+	}
+
+	start := d.offset
+	d.incrementOffset(int(l))
+	return start, d.offset, nil
+}
+
+// subDecoder returns a new decoder that shares the decoder's data and is
+// limited to the given bounds.
+func (d *Decoder) subDecoder(start int, end int) *Decoder {
+	return &Decoder{
+		data:        d.data,
+		offset:      start,
+		start:       start,
+		end:         end,
+		loaded:      true,
+		strings:     d.strings,
+		refs:        d.refs,
+		jsonDefs:    d.jsonDefs,
+		strictUTF8:  d.strictUTF8,
+		maxElements: d.maxElements,
+	}
+}
+
+// load determines the bounds of the decoder's values from its CRC data and
+// index header, if it has one.
+//
+// If the decoder's offset is before the start of its values, then it is moved
+// to the first value.
+func (d *Decoder) load() error {
+	if d.loaded {
+		return nil
+	}
+
+	d.start, d.end, d.tableOffset = 0, 0, 0
+	if len(d.data) > 0 {
+		crcLength := int(d.data[len(d.data)-1])
+		d.end = len(d.data) - crcLength - 1
+	}
+
+	if d.end >= indexHeaderLength && d.data[0] == codingTypeIndex {
+		to, err := d.readIndexSlot(1)
+		if err != nil {
+			return err
+		}
+
+		if to < indexHeaderLength || to > uint64(d.end-8) {
+			return ErrIndex
+		}
+
+		n, err := d.readIndexSlot(int(to))
+		if err != nil {
+			return err
+		}
+
+		if n > uint64(d.end-int(to)-8)/8 || int(to)+8*(int(n)+1) != d.end {
+			return ErrIndex
+		}
+
+		d.start = indexHeaderLength
+		d.end = int(to)
+		d.tableOffset = int(to)
+	}
+
+	if d.offset < d.start {
+		d.offset = d.start
+	}
+
+	d.loaded = true
+	return nil
+}
+
+// readIndexSlot reads the 8 byte index slot at offset o in the decoder's data.
+func (d *Decoder) readIndexSlot(o int) (uint64, error) {
+	if o < 0 || o+8 > len(d.data) {
+		return 0, ErrIndex
+	}
+	return binary.ReadUvarint(bytes.NewReader(d.data[o : o+8]))
+}
+
+// decodeInt64 decodes the next byteLength bytes in to an int64 value.
+func (d *Decoder) decodeInt64(byteLength int) (int64, error) {
+	r, err := d.getIntByteReader(byteLength)
+	if err != nil {
+		return 0, err
+	}
+	return binary.ReadVarint(r)
+}
+
+// decodeUint64 decodes the next byteLength bytes in to an uint64 value.
+func (d *Decoder) decodeUint64(byteLength int) (uint64, error) {
+	r, err := d.getIntByteReader(byteLength)
+	if err != nil {
+		return 0, err
+	}
+	return binary.ReadUvarint(r)
+}
+
+// decodeBits decodes the next uvarint prefixed by its byte length.
+func (d *Decoder) decodeBits() (uint64, error) {
+	if !d.checkLength(1) {
+		return 0, ErrEOB
+	}
+
+	n, err := d.decodeUint64(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return d.decodeUint64(int(n))
+}
+
+// decodeVarint decodes the next variable length varint.
+func (d *Decoder) decodeVarint() (int64, error) {
+	if !d.checkLength(1) {
+		return 0, ErrEOB
+	}
+
+	i, n := binary.Varint(d.data[d.offset:d.end])
+	if n == 0 {
+		return 0, ErrEOB
+	} else if n < 0 {
+		return 0, ErrByteLength
+	}
+
+	d.incrementOffset(n)
+	return i, nil
+}
+
+// decodeUvarint decodes the next variable length uvarint.
+func (d *Decoder) decodeUvarint() (uint64, error) {
+	if !d.checkLength(1) {
+		return 0, ErrEOB
+	}
+
+	i, n := binary.Uvarint(d.data[d.offset:d.end])
+	if n == 0 {
+		return 0, ErrEOB
+	} else if n < 0 {
+		return 0, ErrByteLength
+	}
+
+	d.incrementOffset(n)
+	return i, nil
+}
+
+// decodeTableString decodes the next value if it is a string defined in, or a
+// reference to, the data's string table.
+//
+// If the next value isn't a string table value, then false is returned and the
+// decoder's offset is not changed.
+func (d *Decoder) decodeTableString() (string, bool, error) {
+	if err := d.checkType(codingTypeStringRef); err == nil {
+		r := d.offset - 1
+		n, err := d.decodeUvarint()
+		if err != nil {
+			return "", true, err
+		}
+
+		// The string's definition must come before the reference
+		if n == 0 || n > uint64(r) {
+			return "", true, ErrStringRef
+		}
+
+		s, err := d.tableString(r-int(n), r)
+		return s, true, err
+	} else if err != ErrType {
+		return "", false, err
+	}
+
+	if err := d.checkType(codingTypeStringDef); err == nil {
+		o := d.offset - 1
+		s, err := d.getString()
+		if err != nil {
+			return "", true, err
+		}
+
+		d.strings.store(o, s)
+		return s, true, nil
+	} else if err != ErrType {
+		return "", false, err
+	}
+
+	return "", false, nil
+}
+
+// referencedValue returns the pointer defined at offset o in the decoder's
+// data, which must end before offset end.
+//
+// Definitions that haven't been decoded yet, such as those in skipped values,
+// are decoded from the data.
+func (d *Decoder) referencedValue(o int, end int) (interface{}, error) {
+	if v, ok := d.refs.load(o); ok {
+		return v, nil
+	}
+
+	rd := d.subDecoder(o, end)
+	if err := rd.checkType(codingTypeAnyDef); err != nil {
+		return nil, ErrReference
+	}
+
+	rd.decrementOffset(1)
+	// A definition that doesn't end before the reference isn't valid
+	v, err := rd.DecodeAny()
+	if err == ErrEOB {
+		return nil, ErrReference
+	}
+	return v, err
+}
+
+// tableString returns the string defined at offset o in the decoder's data,
+// which must end before offset end.
+//
+// Definitions that haven't been decoded yet, such as those in skipped values,
+// are decoded from the data.
+func (d *Decoder) tableString(o int, end int) (string, error) {
+	if s, ok := d.strings.load(o); ok {
+		return s.(string), nil
+	}
+
+	sd := d.subDecoder(o, end)
+	if err := sd.checkType(codingTypeStringDef); err != nil {
+		return "", ErrStringRef
+	}
+
+	s, err := sd.getString()
+	if err != nil {
+		return "", ErrStringRef
+	}
+
+	d.strings.store(o, s)
+	return s, nil
+}
+
+// getAddr gets an IP address and its zone.
+func (d *Decoder) getAddr() (netip.Addr, error) {
+	if !d.checkLength(1) {
+		return netip.Addr{}, ErrEOB
+	}
+
+	l := int(d.getByte())
+	if l != 0 && l != 4 && l != 16 {
+		return netip.Addr{}, ErrValue
+	}
+
+	if !d.checkLength(l) {
+		return netip.Addr{}, ErrEOB
+	}
+
+	a, _ := netip.AddrFromSlice(d.getBytes(l))
+	if !a.Is6() {
+		return a, nil
+	}
+
+	zone, err := d.getString()
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return a.WithZone(zone), nil
+}
+
+// getBigInt gets the next arbitrary precision integer prefixed by its sign.
+func (d *Decoder) getBigInt() (*big.Int, error) {
+	if !d.checkLength(1) {
+		return nil, ErrEOB
+	}
+
+	sign := d.getByte()
+	if sign > 1 {
+		return nil, ErrValue
+	}
+
+	b, err := d.getLengthBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	x := new(big.Int).SetBytes(b)
+	if sign == 1 {
+		x.Neg(x)
+	}
+	return x, nil
+}
+
+// getString gets the next string prefixed by its variable length uvarint
+// length.
+func (d *Decoder) getString() (string, error) {
+	b, err := d.getLengthBytes()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// decodeSlice decodes the next value as a packed slice of elements of the
+// given kind.
+func (d *Decoder) decodeSlice(kind byte) (interface{}, error) {
+	n, runLength, err := d.checkSliceType(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	s := makeElements(kind, n)
+	if err := d.getSliceElements(s, runLength); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// checkSliceType checks that the next value is a packed slice of elements of
+// the given kind and returns the number of elements in the slice and whether
+// or not they are run-length encoded.
+//
+// If the value isn't a valid packed slice of the given kind, then the
+// decoder's offset is not changed.
+func (d *Decoder) checkSliceType(kind byte) (int, bool, error) {
+	if err := d.load(); err != nil {
+		return 0, false, err
+	}
+
+	start := d.offset
+	k, n, runLength, err := d.checkSliceHeader()
+	if err != nil {
+		d.offset = start
+		return 0, false, err
+	}
+
+	if k != kind {
+		d.offset = start
+		return 0, false, ErrType
+	}
+	return n, runLength, nil
+}
+
+// checkSliceHeader decodes the header of the next value, which must be a packed
+// or run-length encoded slice, and returns its element kind, its number of
+// elements and whether or not the elements are run-length encoded.
+func (d *Decoder) checkSliceHeader() (byte, int, bool, error) {
+	runLength := false
+	if err := d.checkType(codingTypeRunLengthSlice); err == nil {
+		runLength = true
+	} else if err != ErrType {
+		return 0, 0, false, err
+	} else if err := d.checkType(codingTypeSlice); err != nil {
+		return 0, 0, false, err
+	}
+
+	if !d.checkLength(1) {
+		return 0, 0, false, ErrEOB
+	}
+
+	kind := d.getByte()
+	size := elementSize(kind)
+	if size == 0 {
+		return 0, 0, false, ErrValue
+	}
+
+	if !runLength {
+		n, err := d.getElementCount(size)
+		return kind, n, false, err
+	}
+
+	n, err := d.decodeUvarint()
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	if n > uint64(d.maxElements) {
+		return 0, 0, false, ErrTooLarge
+	}
+	return kind, int(n), true, nil
+}
+
+// getSliceElements gets the next len(s) packed or run-length encoded elements
+// in to the numeric slice s.
+func (d *Decoder) getSliceElements(s interface{}, runLength bool) error {
+	kind, n, _ := elementKind(s)
+	size := elementSize(kind)
+
+	if !runLength {
+		unpackElements(s, d.getBytes(n*size))
+		return nil
+	}
+
+	c, err := d.getElementCount(size + 1)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, n*size)
+	i := 0
+	for j := 0; j < c; j++ {
+		r, err := d.decodeUvarint()
+		if err != nil {
+			return err
+		}
+
+		if r > uint64(n-i) {
+			return ErrValue
+		}
+
+		if !d.checkLength(size) {
+			return ErrEOB
+		}
+
+		v := d.getBytes(size)
+		for k := 0; k < int(r); k++ {
+			copy(b[(i+k)*size:], v)
+		}
+		i += int(r)
+	}
+
+	if i != n {
+		return ErrValue
+	}
+
+	unpackElements(s, b)
+	return nil
+}
+
+// getElementBytes gets the bytes of the next elements prefixed by their
+// variable length uvarint count, where each element is size bytes long.
+func (d *Decoder) getElementBytes(size int) ([]byte, error) {
+	n, err := d.getElementCount(size)
+	if err != nil {
+		return nil, err
+	}
+	return d.getBytes(n * size), nil
+}
+
+// getElementCount gets the next variable length uvarint count of elements and
+// checks that enough bytes follow it for the elements, where each element is
+// size bytes long.
+func (d *Decoder) getElementCount(size int) (int, error) {
+	n, err := d.decodeUvarint()
+	if err != nil {
+		return 0, err
+	}
+
+	if n > uint64(d.end-d.offset)/uint64(size) {
+		return 0, ErrEOB
+	}
+	return int(n), nil
+}
+
+// getLengthBytes gets the next bytes prefixed by their variable length uvarint
+// length.
+func (d *Decoder) getLengthBytes() ([]byte, error) {
+	l, err := d.decodeUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	if l > uint64(d.end-d.offset) {
+		return nil, ErrEOB
+	}
+	return d.getBytes(int(l)), nil
+}
+
+// getIntByteReader creates a new byte reader with the next byteLength bytes.
+func (d *Decoder) getIntByteReader(byteLength int) (*bytes.Reader, error) {
+	if !d.checkLength(byteLength) {
+		return nil, ErrEOB
+	}
+
+	ib := d.getBytes(byteLength)
+	return bytes.NewReader(ib), nil
+}
+
+// checkLength checks that there are enough bytes in the buffer from the
+// decoder's offset to satisfy the given length.
+//
+// The decoder's bounds must have been loaded before calling checkLength.
+func (d *Decoder) checkLength(l int) bool {
+	return l >= 0 && d.offset+l <= d.end
 }
 
 // getByte gets the next byte at offset and increments offset.
@@ -0,0 +1,59 @@
+package coding
+
+import "sync"
+
+// definitions types hold the values that a decoder has decoded from
+// definitions in its data, keyed by the offsets of the definitions.
+//
+// A decoder's sub-decoders share its definitions, and sections may be decoded
+// in parallel, so the values are guarded by a mutex.
+type definitions struct {
+	mu sync.Mutex
+
+	// The decoded values keyed by the offsets of their definitions.
+	values map[int]interface{}
+}
+
+// Initializers
+
+// newDefinitions creates a new, empty set of definitions.
+func newDefinitions() *definitions {
+	return &definitions{
+		values: make(map[int]interface{}),
+	}
+}
+
+// Non-exported methods
+
+// load returns the value defined at offset o, if it has been decoded.
+func (ds *definitions) load(o int) (interface{}, bool) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	v, ok := ds.values[o]
+	return v, ok
+}
+
+// store stores v as the value defined at offset o and returns it.
+//
+// If a value defined at o has already been stored, such as by a section
+// decoded in parallel, then that value is returned along with true instead.
+func (ds *definitions) store(o int, v interface{}) (interface{}, bool) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if x, ok := ds.values[o]; ok {
+		return x, true
+	}
+
+	ds.values[o] = v
+	return v, false
+}
+
+// remove removes the value defined at offset o.
+func (ds *definitions) remove(o int) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	delete(ds.values, o)
+}
@@ -6,6 +6,14 @@ import (
 	"encoding/binary"
 	"hash/crc32"
 	"math"
+	"math/big"
+	"math/bits"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"sort"
+	"time"
+	"unicode/utf8"
 )
 
 // Encoder types encode encode values to binary data.
@@ -13,6 +21,71 @@ type Encoder struct {
 
 	// The encoder's data.
 	data []byte
+
+	// Whether or not the encoder writes an offset index with its data.
+	indexed bool
+
+	// The offsets of the values in the encoder's data.
+	offsets []int
+
+	// The encoder's open sections and records.
+	blocks []block
+
+	// The offsets of the definitions of the strings in the encoder's string
+	// table, or nil if the encoder doesn't use a string table.
+	strings map[string]int
+
+	// Whether or not the encoder run-length encodes packed slices when that is
+	// smaller.
+	runLength bool
+
+	// The offsets of the definitions of the pointers that the encoder has
+	// encoded with EncodeAny, or nil if the encoder doesn't track references.
+	refs map[interface{}]int
+
+	// Whether or not the encoder stores the names of enum values rather than
+	// their ordinals.
+	enumNames bool
+
+	// Whether or not the encoder rejects strings that aren't valid UTF-8.
+	strictUTF8 bool
+
+	// The function that the encoder normalizes strings with, or nil if the
+	// encoder doesn't normalize strings.
+	normalizer func(string) string
+
+	// The offsets of the value definitions that FromJSON has encoded so far, in
+	// the order that they appear in the JSON.
+	jsonDefs []int
+
+	// The first error from an encoding method that doesn't return its errors.
+	err error
+}
+
+// encoderState types are the lengths of an encoder's data, offsets and open
+// blocks, which can be restored to roll back values that fail to encode.
+type encoderState struct {
+	data    int
+	offsets int
+	blocks  int
+}
+
+// block types are open, length-prefixed sections or records.
+type block struct {
+
+	// The block's type.
+	t byte
+
+	// The offset of the block's length slot.
+	offset int
+
+	// The offset of the length slot of the block's open field, or -1 if the
+	// block has no open field.
+	fieldOffset int
+
+	// Whether or not a value has been encoded in the record outside of a
+	// field.
+	stray bool
 }
 
 // Initializers
@@ -26,7 +99,7 @@ func NewEncoder() *Encoder {
 
 // EncodeBool encodes a boolean.
 func (e *Encoder) EncodeBool(b bool) {
-	e.appendByte(codingTypeBool)
+	e.appendType(codingTypeBool)
 	if b {
 		e.appendByte(1)
 	} else {
@@ -34,11 +107,49 @@ func (e *Encoder) EncodeBool(b bool) {
 	}
 }
 
+// EncodeBools encodes a slice of booleans as a bitset.
+func (e *Encoder) EncodeBools(bs []bool) {
+	e.EncodeBitset(NewBitsetFromBools(bs))
+}
+
+// EncodeBitset encodes a bitset.
+//
+// The bitset's bits are packed eight to a byte, or stored as the lengths of
+// their runs when that is smaller, as it is for sparse bitsets. A nil bitset is
+// encoded as a nil value.
+func (e *Encoder) EncodeBitset(b *Bitset) {
+	if b == nil {
+		e.EncodeNil()
+		return
+	}
+
+	e.appendType(codingTypeBitset)
+	e.appendUvarint(uint64(b.n))
+
+	runs := b.runs()
+	l := 0
+	for _, r := range runs {
+		l += uvarintLength(uint64(r))
+	}
+
+	if uvarintLength(uint64(len(runs)))+l < len(b.bits) {
+		e.appendByte(bitsetRunLength)
+		e.appendUvarint(uint64(len(runs)))
+		for _, r := range runs {
+			e.appendUvarint(uint64(r))
+		}
+		return
+	}
+
+	e.appendByte(bitsetPacked)
+	e.appendBytes(b.bits)
+}
+
 // Integer
 
 // EncodeInt encodes an integer.
 func (e *Encoder) EncodeInt(n int) {
-	e.appendByte(codingTypeInt)
+	e.appendType(codingTypeInt)
 	b := make([]byte, 8, 8)
 	_ = binary.PutVarint(b, int64(n))
 	e.appendBytes(b)
@@ -46,7 +157,7 @@ func (e *Encoder) EncodeInt(n int) {
 
 // EncodeInt64 encodes an integer.
 func (e *Encoder) EncodeInt64(n int64) {
-	e.appendByte(codingTypeInt64)
+	e.appendType(codingTypeInt64)
 	b := make([]byte, 8, 8)
 	_ = binary.PutVarint(b, n)
 	e.appendBytes(b)
@@ -54,7 +165,7 @@ func (e *Encoder) EncodeInt64(n int64) {
 
 // EncodeInt32 encodes an integer.
 func (e *Encoder) EncodeInt32(n int32) {
-	e.appendByte(codingTypeInt32)
+	e.appendType(codingTypeInt32)
 	b := make([]byte, 4, 4)
 	_ = binary.PutVarint(b, int64(n))
 	e.appendBytes(b)
@@ -62,7 +173,7 @@ func (e *Encoder) EncodeInt32(n int32) {
 
 // EncodeInt16 encodes an integer.
 func (e *Encoder) EncodeInt16(n int16) {
-	e.appendByte(codingTypeInt16)
+	e.appendType(codingTypeInt16)
 	b := make([]byte, 2, 2)
 	_ = binary.PutVarint(b, int64(n))
 	e.appendBytes(b)
@@ -70,7 +181,7 @@ func (e *Encoder) EncodeInt16(n int16) {
 
 // EncodeInt8 encodes an integer.
 func (e *Encoder) EncodeInt8(n int8) {
-	e.appendByte(codingTypeInt8)
+	e.appendType(codingTypeInt8)
 	b := make([]byte, 1, 1)
 	_ = binary.PutVarint(b, int64(n))
 	e.appendBytes(b)
@@ -80,7 +191,7 @@ func (e *Encoder) EncodeInt8(n int8) {
 
 // EncodeUint encodes an integer.
 func (e *Encoder) EncodeUint(n uint) {
-	e.appendByte(codingTypeUint)
+	e.appendType(codingTypeUint)
 	b := make([]byte, 8, 8)
 	_ = binary.PutUvarint(b, uint64(n))
 	e.appendBytes(b)
@@ -88,7 +199,7 @@ func (e *Encoder) EncodeUint(n uint) {
 
 // EncodeUint64 encodes an integer.
 func (e *Encoder) EncodeUint64(n uint64) {
-	e.appendByte(codingTypeUint64)
+	e.appendType(codingTypeUint64)
 	b := make([]byte, 8, 8)
 	_ = binary.PutUvarint(b, n)
 	e.appendBytes(b)
@@ -96,7 +207,7 @@ func (e *Encoder) EncodeUint64(n uint64) {
 
 // EncodeUint32 encodes an integer.
 func (e *Encoder) EncodeUint32(n uint32) {
-	e.appendByte(codingTypeUint32)
+	e.appendType(codingTypeUint32)
 	b := make([]byte, 4, 4)
 	_ = binary.PutUvarint(b, uint64(n))
 	e.appendBytes(b)
@@ -104,7 +215,7 @@ func (e *Encoder) EncodeUint32(n uint32) {
 
 // EncodeUint16 encodes an integer.
 func (e *Encoder) EncodeUint16(n uint16) {
-	e.appendByte(codingTypeUint16)
+	e.appendType(codingTypeUint16)
 	b := make([]byte, 2, 2)
 	_ = binary.PutUvarint(b, uint64(n))
 	e.appendBytes(b)
@@ -112,7 +223,7 @@ func (e *Encoder) EncodeUint16(n uint16) {
 
 // EncodeUint8 encodes an integer.
 func (e *Encoder) EncodeUint8(n uint8) {
-	e.appendByte(codingTypeUint8)
+	e.appendType(codingTypeUint8)
 	b := make([]byte, 1, 1)
 	_ = binary.PutUvarint(b, uint64(n))
 	e.appendBytes(b)
@@ -123,7 +234,7 @@ func (e *Encoder) EncodeUint8(n uint8) {
 // EncodeFloat64 encodes a float.
 func (e *Encoder) EncodeFloat64(f float64) {
 	// Float type
-	e.appendByte(codingTypeFloat64)
+	e.appendType(codingTypeFloat64)
 
 	// Get bits
 	bits := math.Float64bits(f)
@@ -142,7 +253,7 @@ func (e *Encoder) EncodeFloat64(f float64) {
 // EncodeFloat32 encodes a float.
 func (e *Encoder) EncodeFloat32(f float32) {
 	// Float type
-	e.appendByte(codingTypeFloat32)
+	e.appendType(codingTypeFloat32)
 
 	// Get bits
 	bits := math.Float32bits(f)
@@ -158,22 +269,360 @@ func (e *Encoder) EncodeFloat32(f float32) {
 	e.appendBytes(b[:n])
 }
 
+// Half precision floating point
+
+// EncodeFloat16 encodes a float as an IEEE 754 half precision float.
+//
+// The float is rounded to the nearest half precision value.
+func (e *Encoder) EncodeFloat16(f float32) {
+	e.appendType(codingTypeFloat16)
+	e.appendUint16(float32ToFloat16(f))
+}
+
+// EncodeBFloat16 encodes a float as a bfloat16.
+//
+// The float is rounded to the nearest bfloat16 value.
+func (e *Encoder) EncodeBFloat16(f float32) {
+	e.appendType(codingTypeBFloat16)
+	e.appendUint16(float32ToBFloat16(f))
+}
+
+// EncodeFloat16s encodes a slice of floats as IEEE 754 half precision floats.
+func (e *Encoder) EncodeFloat16s(fs []float32) {
+	e.appendType(codingTypeFloat16Slice)
+	e.appendUvarint(uint64(len(fs)))
+
+	b := make([]byte, 2*len(fs))
+	for i, f := range fs {
+		binary.LittleEndian.PutUint16(b[2*i:], float32ToFloat16(f))
+	}
+	e.appendBytes(b)
+}
+
+// EncodeBFloat16s encodes a slice of floats as bfloat16s.
+func (e *Encoder) EncodeBFloat16s(fs []float32) {
+	e.appendType(codingTypeBFloat16Slice)
+	e.appendUvarint(uint64(len(fs)))
+
+	b := make([]byte, 2*len(fs))
+	for i, f := range fs {
+		binary.LittleEndian.PutUint16(b[2*i:], float32ToBFloat16(f))
+	}
+	e.appendBytes(b)
+}
+
+// Packed slice
+
+// EncodeFloat64s encodes a slice of floats packed in little endian byte order.
+func (e *Encoder) EncodeFloat64s(s []float64) {
+	e.appendSlice(codingTypeFloat64, s)
+}
+
+// EncodeFloat32s encodes a slice of floats packed in little endian byte order.
+func (e *Encoder) EncodeFloat32s(s []float32) {
+	e.appendSlice(codingTypeFloat32, s)
+}
+
+// EncodeInt64s encodes a slice of integers packed in little endian byte order.
+func (e *Encoder) EncodeInt64s(s []int64) {
+	e.appendSlice(codingTypeInt64, s)
+}
+
+// EncodeInt32s encodes a slice of integers packed in little endian byte order.
+func (e *Encoder) EncodeInt32s(s []int32) {
+	e.appendSlice(codingTypeInt32, s)
+}
+
+// EncodeInt16s encodes a slice of integers packed in little endian byte order.
+func (e *Encoder) EncodeInt16s(s []int16) {
+	e.appendSlice(codingTypeInt16, s)
+}
+
+// EncodeInt8s encodes a slice of integers packed in little endian byte order.
+func (e *Encoder) EncodeInt8s(s []int8) {
+	e.appendSlice(codingTypeInt8, s)
+}
+
+// EncodeUint64s encodes a slice of integers packed in little endian byte order.
+func (e *Encoder) EncodeUint64s(s []uint64) {
+	e.appendSlice(codingTypeUint64, s)
+}
+
+// EncodeUint32s encodes a slice of integers packed in little endian byte order.
+func (e *Encoder) EncodeUint32s(s []uint32) {
+	e.appendSlice(codingTypeUint32, s)
+}
+
+// EncodeUint16s encodes a slice of integers packed in little endian byte order.
+func (e *Encoder) EncodeUint16s(s []uint16) {
+	e.appendSlice(codingTypeUint16, s)
+}
+
+// EncodeUint8s encodes a slice of integers packed in little endian byte order.
+func (e *Encoder) EncodeUint8s(s []uint8) {
+	e.appendSlice(codingTypeUint8, s)
+}
+
+// Array
+
+// EncodeArray encodes a fixed-size array, such as a [32]byte digest, or a
+// pointer to one.
+//
+// The array's length is stored as part of its type rather than as a length
+// prefix, and DecodeArray only decodes it in to an array of the same length.
+// ErrType is returned if a isn't an array of a fixed-width numeric type.
+func (e *Encoder) EncodeArray(a interface{}) error {
+	s, kind, ok := arrayElements(a)
+	if !ok {
+		return ErrType
+	}
+
+	_, n, _ := elementKind(s)
+	e.appendType(codingTypeArray)
+	e.appendByte(kind)
+	e.appendUvarint(uint64(n))
+	e.appendElements(s, n*elementSize(kind))
+	return nil
+}
+
+// Sparse vector
+
+// EncodeSparseVector encodes a sparse vector.
+//
+// The vector's length is written followed by the differences between its
+// consecutive indices and its packed values. A nil vector is encoded as a nil
+// value.
+func (e *Encoder) EncodeSparseVector(v *SparseVector) {
+	if v == nil {
+		e.EncodeNil()
+		return
+	}
+
+	kind, n, _ := elementKind(v.values)
+
+	e.appendType(codingTypeSparseVector)
+	e.appendByte(kind)
+	e.appendUvarint(uint64(v.length))
+	e.appendUvarint(uint64(len(v.indices)))
+
+	prev := 0
+	for _, i := range v.indices {
+		e.appendUvarint(uint64(i - prev))
+		prev = i
+	}
+
+	e.appendElements(v.values, n*elementSize(kind))
+}
+
+// EncodeSparseFloat32s encodes the nonzero elements of a dense slice as a
+// sparse vector.
+func (e *Encoder) EncodeSparseFloat32s(dense []float32) {
+	e.EncodeSparseVector(NewSparseVectorFromFloat32s(dense))
+}
+
+// EncodeSparseFloat64s encodes the nonzero elements of a dense slice as a
+// sparse vector.
+func (e *Encoder) EncodeSparseFloat64s(dense []float64) {
+	e.EncodeSparseVector(NewSparseVectorFromFloat64s(dense))
+}
+
+// Series
+
+// EncodeInt64Series encodes a series of integers, such as timestamps or
+// counters, as the differences between consecutive values.
+//
+// Values are stored as zigzag varint deltas, or as deltas of deltas when that
+// is smaller, as it is for regularly spaced timestamps. The series is most
+// compact when its values change slowly or at a steady rate.
+func (e *Encoder) EncodeInt64Series(s []int64) {
+	deltas := seriesDeltas(s)
+
+	mode := seriesDelta
+	if len(s) > 2 {
+		if dods := seriesDeltas(deltas[1:]); varintsLength(dods) < varintsLength(deltas[1:]) {
+			mode = seriesDeltaOfDelta
+			deltas = append(deltas[:1], dods...)
+		}
+	}
+
+	e.appendType(codingTypeInt64Series)
+	e.appendByte(mode)
+	e.appendUvarint(uint64(len(s)))
+
+	for _, d := range deltas {
+		e.appendVarint(d)
+	}
+}
+
+// EncodeFloat64Series encodes a series of floats, such as sensor readings,
+// using Gorilla XOR compression.
+//
+// Each value is XORed with the previous one and only the meaningful bits of
+// the result are stored, so slowly changing values take only a few bits.
+func (e *Encoder) EncodeFloat64Series(s []float64) {
+	w := new(bitWriter)
+
+	var prev uint64
+	leading, trailing := -1, 0
+	for i, f := range s {
+		v := math.Float64bits(f)
+		if i == 0 {
+			w.writeBits(v, 64)
+			prev = v
+			continue
+		}
+
+		x := v ^ prev
+		prev = v
+		if x == 0 {
+			w.writeBit(false)
+			continue
+		}
+		w.writeBit(true)
+
+		l, t := bits.LeadingZeros64(x), bits.TrailingZeros64(x)
+		if l > 31 {
+			l = 31
+		}
+
+		// Reuse the previous window of meaningful bits if the value fits in it
+		if leading >= 0 && l >= leading && t >= trailing {
+			w.writeBit(false)
+			w.writeBits(x>>uint(trailing), 64-leading-trailing)
+			continue
+		}
+
+		leading, trailing = l, t
+		w.writeBit(true)
+		w.writeBits(uint64(leading), 5)
+		w.writeBits(uint64(64-leading-trailing), 6)
+		w.writeBits(x>>uint(trailing), 64-leading-trailing)
+	}
+
+	e.appendType(codingTypeFloat64Series)
+	e.appendUvarint(uint64(len(s)))
+	e.appendUvarint(uint64(len(w.b)))
+	e.appendBytes(w.b)
+}
+
+// Tensor
+
+// EncodeTensor encodes a tensor.
+//
+// The tensor's element kind and shape are written once, followed by its
+// elements packed in little endian byte order. A nil tensor is encoded as a nil
+// value.
+func (e *Encoder) EncodeTensor(t *Tensor) {
+	if t == nil {
+		e.EncodeNil()
+		return
+	}
+
+	kind, n, _ := elementKind(t.data)
+
+	e.appendType(codingTypeTensor)
+	e.appendByte(kind)
+	e.appendUvarint(uint64(len(t.shape)))
+	for _, s := range t.shape {
+		e.appendUvarint(uint64(s))
+	}
+
+	e.appendElements(t.data, n*elementSize(kind))
+}
+
+// Complex
+
+// EncodeComplex128 encodes a complex number.
+func (e *Encoder) EncodeComplex128(c complex128) {
+	e.appendType(codingTypeComplex128)
+	e.appendBits(math.Float64bits(real(c)))
+	e.appendBits(math.Float64bits(imag(c)))
+}
+
+// EncodeComplex64 encodes a complex number.
+func (e *Encoder) EncodeComplex64(c complex64) {
+	e.appendType(codingTypeComplex64)
+	e.appendBits(uint64(math.Float32bits(real(c))))
+	e.appendBits(uint64(math.Float32bits(imag(c))))
+}
+
+// 128-bit integer
+
+// EncodeInt128 encodes an integer.
+func (e *Encoder) EncodeInt128(n Int128) {
+	e.appendType(codingTypeInt128)
+	e.appendBits(uint64(n.Hi))
+	e.appendBits(n.Lo)
+}
+
+// EncodeUint128 encodes an integer.
+func (e *Encoder) EncodeUint128(n Uint128) {
+	e.appendType(codingTypeUint128)
+	e.appendBits(n.Hi)
+	e.appendBits(n.Lo)
+}
+
 // Data
 
 // EncodeString encodes the string.
+//
+// If the encoder uses a string table, then only the first occurrence of the
+// string is stored and later occurrences refer to it. If the encoder is strict
+// and the string isn't valid UTF-8, then nothing is encoded, the encoder's Err
+// method returns ErrUTF8 and its Data method returns nil until it is flushed.
+// Strings are normalized with the encoder's normalizer, if it has one, before
+// they are encoded.
 func (e *Encoder) EncodeString(s string) {
-	e.appendByte(codingTypeString)
+	if err := e.encodeString(s); err != nil && e.err == nil {
+		e.err = err
+	}
+}
+
+// EncodeRunes encodes the runes as a UTF-8 string.
+//
+// Runes that aren't valid Unicode code points are encoded as the replacement
+// character, unless the encoder is strict, in which case ErrUTF8 is returned
+// and nothing is encoded.
+func (e *Encoder) EncodeRunes(rs []rune) error {
+	if e.strictUTF8 {
+		for _, r := range rs {
+			if !utf8.ValidRune(r) {
+				return ErrUTF8
+			}
+		}
+	}
+	return e.encodeString(string(rs))
+}
+
+// encodeString encodes the string s, returning ErrUTF8 if the encoder is strict
+// and s isn't valid UTF-8.
+func (e *Encoder) encodeString(s string) error {
+	if e.strictUTF8 && !utf8.ValidString(s) {
+		return ErrUTF8
+	}
+
+	if e.normalizer != nil {
+		s = e.normalizer(s)
+	}
+
+	if e.strings != nil {
+		e.appendTableString(s)
+		return nil
+	}
+
+	e.appendType(codingTypeString)
 
 	b := make([]byte, 8, 8)
 	_ = binary.PutVarint(b, int64(len(s)))
 	e.appendBytes(b)
 
 	e.appendBytes([]byte(s))
+	return nil
 }
 
 // EncodeData encodes the data.
 func (e *Encoder) EncodeData(b []byte) {
-	e.appendByte(codingTypeData)
+	e.appendType(codingTypeData)
 
 	bytes := make([]byte, 8, 8)
 	_ = binary.PutVarint(bytes, int64(len(b)))
@@ -182,23 +631,531 @@ func (e *Encoder) EncodeData(b []byte) {
 	e.appendBytes(b)
 }
 
+// Arbitrary precision
+
+// EncodeBigInt encodes an arbitrary precision integer.
+//
+// A nil integer is encoded as a nil value.
+func (e *Encoder) EncodeBigInt(x *big.Int) {
+	if x == nil {
+		e.EncodeNil()
+		return
+	}
+
+	e.appendType(codingTypeBigInt)
+	e.appendBigInt(x)
+}
+
+// EncodeBigRat encodes an arbitrary precision rational number.
+//
+// A nil rational number is encoded as a nil value.
+func (e *Encoder) EncodeBigRat(x *big.Rat) {
+	if x == nil {
+		e.EncodeNil()
+		return
+	}
+
+	e.appendType(codingTypeBigRat)
+	e.appendBigInt(x.Num())
+	e.appendBigInt(x.Denom())
+}
+
+// EncodeBigFloat encodes an arbitrary precision floating point number along
+// with its precision and rounding mode.
+//
+// A nil floating point number is encoded as a nil value.
+func (e *Encoder) EncodeBigFloat(x *big.Float) {
+	if x == nil {
+		e.EncodeNil()
+		return
+	}
+
+	// Gob encoding is exact and never fails
+	b, _ := x.GobEncode()
+
+	e.appendType(codingTypeBigFloat)
+	e.appendUvarint(uint64(len(b)))
+	e.appendBytes(b)
+}
+
+// Decimal
+
+// EncodeDecimal encodes a fixed-point decimal.
+func (e *Encoder) EncodeDecimal(x Decimal) {
+	e.appendType(codingTypeDecimal)
+	e.appendVarint(x.unscaled)
+	e.appendVarint(int64(x.scale))
+}
+
+// Time
+
+// EncodeTime encodes a time.
+//
+// The time is encoded with nanosecond precision along with its location. UTC
+// times and times in named locations keep their locations, and all other times
+// keep their zone offsets. The time's monotonic clock reading is discarded.
+func (e *Encoder) EncodeTime(t time.Time) {
+	e.appendType(codingTypeTime)
+	e.appendVarint(t.Unix())
+	e.appendUvarint(uint64(t.Nanosecond()))
+
+	name, offset := t.Zone()
+	switch l := t.Location().String(); {
+	case t.Location() == time.UTC:
+		e.appendByte(timeZoneUTC)
+	case l == "" || l == "Local" || l == name:
+		e.appendByte(timeZoneOffset)
+		e.appendVarint(int64(offset))
+		e.appendString(name)
+	default:
+		e.appendByte(timeZoneLocation)
+		e.appendVarint(int64(offset))
+		e.appendString(l)
+	}
+}
+
+// EncodeDuration encodes a duration.
+func (e *Encoder) EncodeDuration(d time.Duration) {
+	e.appendType(codingTypeDuration)
+	e.appendVarint(int64(d))
+}
+
+// Identifier
+
+// EncodeUUID encodes a UUID.
+func (e *Encoder) EncodeUUID(u UUID) {
+	e.appendType(codingTypeUUID)
+	e.appendBytes(u[:])
+}
+
+// Network
+
+// EncodeAddr encodes an IP address and its zone.
+func (e *Encoder) EncodeAddr(a netip.Addr) {
+	e.appendType(codingTypeAddr)
+	e.appendAddr(a)
+}
+
+// EncodePrefix encodes an IP prefix.
+func (e *Encoder) EncodePrefix(p netip.Prefix) {
+	e.appendType(codingTypePrefix)
+	e.appendAddr(p.Addr())
+	e.appendVarint(int64(p.Bits()))
+}
+
+// EncodeAddrPort encodes an IP address and port.
+func (e *Encoder) EncodeAddrPort(ap netip.AddrPort) {
+	e.appendType(codingTypeAddrPort)
+	e.appendAddr(ap.Addr())
+	e.appendUint16(ap.Port())
+}
+
+// EncodeURL encodes a URL.
+//
+// A nil URL is encoded as a nil value.
+func (e *Encoder) EncodeURL(u *url.URL) {
+	if u == nil {
+		e.EncodeNil()
+		return
+	}
+
+	e.appendType(codingTypeURL)
+	e.appendString(u.String())
+}
+
+// Nil
+
+// EncodeNil encodes a nil value.
+//
+// Use EncodeNil in place of an optional value that is absent. Nil values can
+// be decoded with DecodeNil or any of the decoder's nullable decode methods.
+func (e *Encoder) EncodeNil() {
+	e.appendType(codingTypeNil)
+}
+
+// Any
+
+// EncodeAny encodes a value of a type registered with Register.
+//
+// The value is encoded with its type's ID so that DecodeAny can decode it in to
+// a new value of the same type. Nil values are encoded with EncodeNil.
+//
+// If the encoder tracks references, then a pointer that has already been
+// encoded is encoded as a reference to its first occurrence.
+func (e *Encoder) EncodeAny(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if v == nil || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		e.EncodeNil()
+		return nil
+	}
+
+	r, ok := lookupType(rv.Type())
+	if !ok {
+		return ErrUnregistered
+	}
+
+	// Roll back anything written if the value fails to encode
+	st := e.state()
+
+	t := codingTypeAny
+	if e.refs != nil && rv.Kind() == reflect.Ptr {
+		// References store the distance back to their pointer's definition
+		if o, ok := e.refs[v]; ok {
+			r := len(e.data)
+			e.appendType(codingTypeAnyRef)
+			e.appendUvarint(uint64(r - o))
+			return nil
+		}
+
+		// Add the pointer before its value is encoded so that values that
+		// refer back to it are encoded as references
+		t = codingTypeAnyDef
+		e.refs[v] = len(e.data)
+	}
+
+	e.beginBlock(t)
+	id := make([]byte, 4, 4)
+	binary.LittleEndian.PutUint32(id, r.id)
+	e.appendBytes(id)
+
+	if err := e.encodeValue(rv); err != nil {
+		e.restore(st)
+		return err
+	}
+
+	e.endBlock()
+	return nil
+}
+
+// Set
+
+// EncodeSet encodes a set of numbers or strings.
+//
+// The set's elements, s, must be a slice of a fixed-width numeric type or a
+// []string. They are encoded in ascending order without duplicates, so equal
+// sets are always encoded the same way. ErrType is returned if s isn't a slice
+// of a supported type, ErrValue is returned if s contains NaN, and ErrUTF8 is
+// returned if the encoder is strict and s contains invalid UTF-8. Strings are
+// normalized with the encoder's normalizer, if it has one, before they are
+// sorted, so strings that normalize to the same string are encoded once.
+func (e *Encoder) EncodeSet(s interface{}) error {
+	kind, ok := setKind(s)
+	if !ok {
+		return ErrType
+	}
+
+	if ss, ok := s.([]string); ok && e.strictUTF8 {
+		for _, x := range ss {
+			if !utf8.ValidString(x) {
+				return ErrUTF8
+			}
+		}
+	}
+
+	if ss, ok := s.([]string); ok && e.normalizer != nil {
+		ns := make([]string, len(ss))
+		for i, x := range ss {
+			ns[i] = e.normalizer(x)
+		}
+		s = ns
+	}
+
+	s, err := sortedSet(s)
+	if err != nil {
+		return err
+	}
+
+	e.appendType(codingTypeSet)
+	e.appendByte(kind)
+
+	if ss, ok := s.([]string); ok {
+		e.appendUvarint(uint64(len(ss)))
+		for _, x := range ss {
+			e.appendString(x)
+		}
+		return nil
+	}
+
+	_, n, _ := elementKind(s)
+	e.appendUvarint(uint64(n))
+	e.appendElements(s, n*elementSize(kind))
+	return nil
+}
+
+// Map
+
+// EncodeOrderedMap encodes an ordered map with its keys in the order that they
+// were added.
+//
+// The map's values are encoded with EncodeAny. If a value fails to encode, or
+// the encoder is strict and a key isn't valid UTF-8, then an error is returned
+// and nothing is encoded. Keys are normalized with the encoder's normalizer, if
+// it has one, and ErrMap is returned if two keys normalize to the same string.
+func (e *Encoder) EncodeOrderedMap(m *OrderedMap) error {
+	return e.encodeMap(mapInsertionOrder, m)
+}
+
+// EncodeSortedMap encodes an ordered map with its keys in ascending order, so
+// that maps with the same keys and values are always encoded the same way.
+//
+// The map's values are encoded with EncodeAny. If a value fails to encode, or
+// the encoder is strict and a key isn't valid UTF-8, then an error is returned
+// and nothing is encoded. Keys are normalized as they are by EncodeOrderedMap
+// before they are sorted.
+func (e *Encoder) EncodeSortedMap(m *OrderedMap) error {
+	return e.encodeMap(mapSorted, m)
+}
+
+// Enum
+
+// EncodeEnum encodes a value of an integer type registered with RegisterEnum.
+//
+// The value is encoded as its ordinal, or as its name if the encoder stores
+// enum names. ErrUnregistered is returned if the value's type isn't registered,
+// and ErrEnum is returned if the value has no name.
+func (e *Encoder) EncodeEnum(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if v == nil {
+		return ErrUnregistered
+	}
+
+	en, ok := lookupEnum(rv.Type())
+	if !ok {
+		return ErrUnregistered
+	}
+
+	n := enumInt64(rv)
+	name, ok := en.names[n]
+	if !ok {
+		return ErrEnum
+	}
+
+	e.appendType(codingTypeEnum)
+	if e.enumNames {
+		e.appendByte(enumName)
+		e.appendString(name)
+	} else {
+		e.appendByte(enumOrdinal)
+		e.appendVarint(n)
+	}
+	return nil
+}
+
+// Section
+
+// BeginSection begins a length-prefixed section.
+//
+// Values encoded until the matching call to EndSection are grouped in the
+// section so that a decoder may enter the section or skip over it entirely.
+// Sections may be nested.
+func (e *Encoder) BeginSection() {
+	e.beginBlock(codingTypeSection)
+}
+
+// EndSection ends the most recently begun section.
+func (e *Encoder) EndSection() error {
+	if !e.inBlock(codingTypeSection) {
+		return ErrNoSection
+	}
+
+	e.endBlock()
+	return nil
+}
+
+// Record
+
+// BeginRecord begins a record of numbered fields.
+//
+// Each value in a record must follow a call to Field. A decoder may then
+// look up the record's fields by number, skipping fields it doesn't know
+// about, so fields may be added to or removed from a record without breaking
+// older or newer decoders. A record with values before its first field can't
+// be decoded, so EndRecord and the encoder's Err method return ErrNoField for
+// it.
+func (e *Encoder) BeginRecord() {
+	e.beginBlock(codingTypeRecord)
+}
+
+// Field begins the field numbered n in the most recently begun record.
+//
+// The values encoded until the next call to Field or EndRecord belong to the
+// field.
+func (e *Encoder) Field(n int) error {
+	if !e.inBlock(codingTypeRecord) {
+		return ErrNoRecord
+	}
+
+	if n < 0 || n > math.MaxInt32 {
+		return ErrFieldNumber
+	}
+
+	e.endField()
+	e.appendUvarint(uint64(n))
+
+	e.blocks[len(e.blocks)-1].fieldOffset = len(e.data)
+	e.appendBytes(make([]byte, 8, 8))
+	return nil
+}
+
+// EndRecord ends the most recently begun record.
+//
+// ErrNoField is returned if a value was encoded in the record before its first
+// field.
+func (e *Encoder) EndRecord() error {
+	if !e.inBlock(codingTypeRecord) {
+		return ErrNoRecord
+	}
+
+	stray := e.blocks[len(e.blocks)-1].stray
+	e.endField()
+	e.endBlock()
+
+	if stray {
+		return ErrNoField
+	}
+	return nil
+}
+
 // Exported methods
 
 // Data returns the encoder's data along with trailing CRC data.
+//
+// All sections and records must be ended before calling Data, since their
+// lengths aren't written until then, and Data panics if one is still open. If
+// an encoding method that doesn't return its errors has failed, such as a
+// strict encoder's EncodeString, then the data is missing a value, so nil is
+// returned until the encoder is flushed.
+//
+// If the encoder is indexed, then the data is wrapped with an index header and
+// a trailing table of value offsets before the CRC data is added.
 func (e Encoder) Data() []byte {
-	return append(e.data, e.crcBytes()...)
+	if len(e.blocks) > 0 {
+		panic("coding: Data called with an open section or record")
+	}
+
+	if e.err != nil {
+		return nil
+	}
+
+	if e.indexed {
+		d := e.indexData()
+		return append(d, crcBytes(d)...)
+	}
+	return append(e.data, crcBytes(e.data)...)
+}
+
+// Err returns the first error from an encoding method that doesn't return its
+// errors, such as a strict encoder's EncodeString, or nil if there hasn't been
+// one since the encoder was created or flushed.
+func (e Encoder) Err() error {
+	return e.err
 }
 
-// Flush clears the encoder's data.
+// Flush clears the encoder's data and error.
 func (e *Encoder) Flush() {
 	e.data = nil
+	e.offsets = nil
+	e.blocks = nil
+	e.err = nil
+
+	if e.strings != nil {
+		e.strings = make(map[string]int)
+	}
+
+	if e.refs != nil {
+		e.refs = make(map[interface{}]int)
+	}
+}
+
+// SetIndexed sets whether or not the encoder writes an offset index with its
+// data so that a decoder may seek directly to any value.
+//
+// Only values encoded while the encoder is indexed are added to the index, so
+// SetIndexed should be called before encoding any values. Values inside of
+// sections and records are not indexed, but the sections and records
+// themselves are.
+func (e *Encoder) SetIndexed(indexed bool) {
+	e.indexed = indexed
+}
+
+// SetStringTable sets whether or not the encoder stores repeated strings in a
+// string table.
+//
+// With a string table, the first occurrence of each string is stored and later
+// occurrences are encoded as small references to it. References point back to
+// the string's position in the data, so they can be decoded after skipping or
+// seeking past the string that they refer to. Disabling the table clears it.
+func (e *Encoder) SetStringTable(enabled bool) {
+	if !enabled {
+		e.strings = nil
+	} else if e.strings == nil {
+		e.strings = make(map[string]int)
+	}
+}
+
+// SetReferences sets whether or not the encoder tracks the pointers that it
+// encodes with EncodeAny.
+//
+// When references are tracked, the first occurrence of each pointer is encoded
+// in full and later occurrences are encoded as references to it, so shared and
+// cyclic values can be encoded and DecodeAny rebuilds the same aliasing. Like
+// string table references, references point back to their value's position in
+// the data, so they can be decoded after skipping or seeking past the value.
+// Disabling references clears the tracked pointers.
+func (e *Encoder) SetReferences(enabled bool) {
+	if !enabled {
+		e.refs = nil
+	} else if e.refs == nil {
+		e.refs = make(map[interface{}]int)
+	}
+}
+
+// SetEnumNames sets whether or not the encoder stores the names of enum values
+// rather than their ordinals.
+//
+// Ordinals are smaller, but names keep encoded data readable and valid if an
+// enum's values are renumbered. Decoders accept either form.
+func (e *Encoder) SetEnumNames(enabled bool) {
+	e.enumNames = enabled
+}
+
+// SetStrictUTF8 sets whether or not the encoder rejects strings and runes that
+// aren't valid UTF-8.
+func (e *Encoder) SetStrictUTF8(enabled bool) {
+	e.strictUTF8 = enabled
+}
+
+// SetNormalizer sets the function that the encoder normalizes strings, set
+// elements and map keys with before encoding them, or disables normalization
+// if f is nil.
+//
+// The standard library doesn't implement Unicode normalization, so to store
+// text in NFC pass a function such as norm.NFC.String from the
+// golang.org/x/text/unicode/norm package.
+func (e *Encoder) SetNormalizer(f func(string) string) {
+	e.normalizer = f
+}
+
+// SetRunLength sets whether or not the encoder stores packed slices as runs of
+// equal elements when that is smaller than packing each element.
+//
+// Decoders expand run-length encoded slices transparently. Checking for runs
+// costs a pass over each slice, so it is disabled by default.
+func (e *Encoder) SetRunLength(enabled bool) {
+	e.runLength = enabled
 }
 
 // Compress compresses the encoder's data and returns the result.
 //
 // Compress calls the encoder's Data function so that its data's CRC is included
-// in the compressed bytes.
+// in the compressed bytes, so it panics if a section or record is still open.
+// If an encoding method that doesn't return its errors has failed, then its
+// error is returned.
 func (e *Encoder) Compress() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+
 	var cmb bytes.Buffer
 	w := zlib.NewWriter(&cmb)
 
@@ -215,19 +1172,305 @@ func (e *Encoder) Compress() ([]byte, error) {
 
 // Non-exported methods
 
-// crcBytes calculates the CRC32 of the encoder's data and returns the bytes
-// that should be added to the data.
-func (e *Encoder) crcBytes() []byte {
-	crc := crc32.ChecksumIEEE(e.data)
-	b := make([]byte, 16, 16)
-	n := binary.PutUvarint(b, uint64(crc))
+// indexData returns the encoder's data prefixed with an index header and
+// followed by its offset table.
+func (e Encoder) indexData() []byte {
+	tableOffset := indexHeaderLength + len(e.data)
+
+	o := make([]byte, 0, tableOffset+8*(len(e.offsets)+1))
+	o = append(o, codingTypeIndex)
+	o = append(o, uvarintBytes(uint64(tableOffset), 8)...)
+	o = append(o, e.data...)
+
+	o = append(o, uvarintBytes(uint64(len(e.offsets)), 8)...)
+	for _, offset := range e.offsets {
+		o = append(o, uvarintBytes(uint64(indexHeaderLength+offset), 8)...)
+	}
 
-	var o []byte
-	o = append(o, b[:n]...)
-	o = append(o, byte(n))
 	return o
 }
 
+// encodeMap encodes the map m with its keys in insertion order, or in ascending
+// order if order is mapSorted.
+//
+// Keys are normalized with the encoder's normalizer before they are sorted, and
+// ErrMap is returned if two keys normalize to the same string.
+func (e *Encoder) encodeMap(order byte, m *OrderedMap) error {
+	keys := m.Keys()
+	names := keys
+	if e.normalizer != nil {
+		names = make([]string, len(keys))
+		seen := make(map[string]bool, len(keys))
+		for i, k := range keys {
+			names[i] = e.normalizer(k)
+			if seen[names[i]] {
+				return ErrMap
+			}
+			seen[names[i]] = true
+		}
+	}
+
+	idx := make([]int, len(keys))
+	for i := range idx {
+		idx[i] = i
+	}
+
+	if order == mapSorted {
+		sort.Slice(idx, func(i, j int) bool {
+			return names[idx[i]] < names[idx[j]]
+		})
+	}
+
+	st := e.state()
+
+	e.appendType(codingTypeMap)
+	e.appendByte(order)
+	e.appendUvarint(uint64(len(keys)))
+
+	for _, i := range idx {
+		if e.strictUTF8 && !utf8.ValidString(keys[i]) {
+			e.restore(st)
+			return ErrUTF8
+		}
+
+		e.appendString(names[i])
+		if err := e.EncodeAny(m.values[keys[i]]); err != nil {
+			e.restore(st)
+			return err
+		}
+	}
+	return nil
+}
+
+// state returns the encoder's current state.
+func (e *Encoder) state() encoderState {
+	return encoderState{
+		data:    len(e.data),
+		offsets: len(e.offsets),
+		blocks:  len(e.blocks),
+	}
+}
+
+// restore rolls the encoder back to the given state, removing the values and
+// table entries added since.
+func (e *Encoder) restore(st encoderState) {
+	e.data, e.offsets, e.blocks = e.data[:st.data], e.offsets[:st.offsets], e.blocks[:st.blocks]
+
+	for s, o := range e.strings {
+		if o >= st.data {
+			delete(e.strings, s)
+		}
+	}
+
+	for p, o := range e.refs {
+		if o >= st.data {
+			delete(e.refs, p)
+		}
+	}
+}
+
+// beginBlock appends a block of type t with an empty length slot and makes it
+// the encoder's innermost open block.
+func (e *Encoder) beginBlock(t byte) {
+	e.appendType(t)
+	e.blocks = append(e.blocks, block{
+		t:           t,
+		offset:      len(e.data),
+		fieldOffset: -1,
+	})
+	e.appendBytes(make([]byte, 8, 8))
+}
+
+// inBlock returns whether or not the encoder's innermost open block has type
+// t.
+func (e *Encoder) inBlock(t byte) bool {
+	b := e.innerBlock()
+	return b != nil && b.t == t
+}
+
+// innerBlock returns the encoder's innermost open block, or nil if it has no
+// open blocks.
+func (e *Encoder) innerBlock() *block {
+	if len(e.blocks) == 0 {
+		return nil
+	}
+	return &e.blocks[len(e.blocks)-1]
+}
+
+// endBlock writes the length of the encoder's innermost open block and closes
+// it.
+func (e *Encoder) endBlock() {
+	b := e.blocks[len(e.blocks)-1]
+	e.blocks = e.blocks[:len(e.blocks)-1]
+	e.putLength(b.offset)
+}
+
+// endField writes the length of the innermost open block's open field, if it
+// has one, and closes it.
+func (e *Encoder) endField() {
+	b := &e.blocks[len(e.blocks)-1]
+	if b.fieldOffset < 0 {
+		return
+	}
+
+	e.putLength(b.fieldOffset)
+	b.fieldOffset = -1
+}
+
+// putLength writes the number of bytes following the 8 byte length slot at
+// offset o in to the slot.
+func (e *Encoder) putLength(o int) {
+	l := len(e.data) - o - 8
+	_ = binary.PutVarint(e.data[o:o+8], int64(l))
+}
+
+// appendType records the offset of a new value, if necessary, and appends the
+// value's type byte to the encoder's data.
+//
+// If the encoder's innermost open block is a record without an open field,
+// then the record is marked as invalid and the encoder's error is set to
+// ErrNoField.
+func (e *Encoder) appendType(t byte) {
+	if e.indexed && len(e.blocks) == 0 {
+		e.offsets = append(e.offsets, len(e.data))
+	}
+
+	if b := e.innerBlock(); b != nil && b.t == codingTypeRecord && b.fieldOffset < 0 {
+		b.stray = true
+		if e.err == nil {
+			e.err = ErrNoField
+		}
+	}
+	e.appendByte(t)
+}
+
+// appendSlice appends a packed slice of elements of the given kind.
+//
+// If the encoder run-length encodes slices, and storing the slice's runs of
+// equal elements is smaller than packing each of its elements, then a
+// run-length encoded slice is appended instead.
+func (e *Encoder) appendSlice(kind byte, s interface{}) {
+	_, n, _ := elementKind(s)
+	size := elementSize(kind)
+
+	o := len(e.data)
+	e.appendType(codingTypeSlice)
+	e.appendByte(kind)
+	e.appendUvarint(uint64(n))
+
+	p := len(e.data)
+	e.appendElements(s, n*size)
+
+	if !e.runLength {
+		return
+	}
+
+	runs, ok := elementRuns(e.data[p:], size)
+	if !ok {
+		return
+	}
+
+	// Collect each run's length and element before replacing the elements
+	var b []byte
+	b = append(b, uvarintBytes(uint64(len(runs)), uvarintLength(uint64(len(runs))))...)
+	i := p
+	for _, r := range runs {
+		b = append(b, uvarintBytes(uint64(r), uvarintLength(uint64(r)))...)
+		b = append(b, e.data[i:i+size]...)
+		i += r * size
+	}
+
+	e.data[o] = codingTypeRunLengthSlice
+	e.data = append(e.data[:p], b...)
+}
+
+// appendElements appends the l bytes of the elements of the numeric slice s in
+// little endian byte order.
+func (e *Encoder) appendElements(s interface{}, l int) {
+	o := len(e.data)
+	e.data = append(e.data, make([]byte, l)...)
+	packElements(e.data[o:], s)
+}
+
+// appendUint16 appends n in little endian byte order.
+func (e *Encoder) appendUint16(n uint16) {
+	b := make([]byte, 2, 2)
+	binary.LittleEndian.PutUint16(b, n)
+	e.appendBytes(b)
+}
+
+// appendBits appends the byte length of bits as a uvarint followed by the
+// uvarint itself.
+func (e *Encoder) appendBits(bits uint64) {
+	b := make([]byte, 16, 16)
+	n := binary.PutUvarint(b, bits)
+	e.appendByte(byte(n))
+	e.appendBytes(b[:n])
+}
+
+// appendVarint appends n as a variable length varint.
+func (e *Encoder) appendVarint(n int64) {
+	b := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64)
+	e.appendBytes(b[:binary.PutVarint(b, n)])
+}
+
+// appendUvarint appends n as a variable length uvarint.
+func (e *Encoder) appendUvarint(n uint64) {
+	b := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64)
+	e.appendBytes(b[:binary.PutUvarint(b, n)])
+}
+
+// appendTableString appends a reference to s in the encoder's string table,
+// or defines s in the table if it isn't there yet.
+//
+// References store the distance back to their string's definition, so they
+// don't depend on which values a decoder has already decoded.
+func (e *Encoder) appendTableString(s string) {
+	if o, ok := e.strings[s]; ok {
+		r := len(e.data)
+		e.appendType(codingTypeStringRef)
+		e.appendUvarint(uint64(r - o))
+		return
+	}
+
+	e.strings[s] = len(e.data)
+	e.appendType(codingTypeStringDef)
+	e.appendString(s)
+}
+
+// appendAddr appends the length and bytes of a, which is zero for the zero
+// address, followed by its zone if a is an IPv6 address.
+func (e *Encoder) appendAddr(a netip.Addr) {
+	b := a.AsSlice()
+	e.appendByte(byte(len(b)))
+	e.appendBytes(b)
+
+	if a.Is6() {
+		e.appendString(a.Zone())
+	}
+}
+
+// appendBigInt appends the sign of x followed by the length and bytes of its
+// absolute value.
+func (e *Encoder) appendBigInt(x *big.Int) {
+	if x.Sign() < 0 {
+		e.appendByte(1)
+	} else {
+		e.appendByte(0)
+	}
+
+	b := x.Bytes()
+	e.appendUvarint(uint64(len(b)))
+	e.appendBytes(b)
+}
+
+// appendString appends the length of s as a variable length uvarint followed
+// by the bytes of s.
+func (e *Encoder) appendString(s string) {
+	e.appendUvarint(uint64(len(s)))
+	e.appendBytes([]byte(s))
+}
+
 // appendByte appends a single byte to the encoder's data.
 func (e *Encoder) appendByte(b byte) {
 	e.data = append(e.data, b)
@@ -237,3 +1480,63 @@ func (e *Encoder) appendByte(b byte) {
 func (e *Encoder) appendBytes(b []byte) {
 	e.data = append(e.data, b...)
 }
+
+// Non-exported functions
+
+// crcBytes calculates the CRC32 of the data and returns the bytes that should
+// be added to the data.
+func crcBytes(data []byte) []byte {
+	crc := crc32.ChecksumIEEE(data)
+	b := make([]byte, 16, 16)
+	n := binary.PutUvarint(b, uint64(crc))
+
+	var o []byte
+	o = append(o, b[:n]...)
+	o = append(o, byte(n))
+	return o
+}
+
+// seriesDeltas returns the first value in s followed by the differences
+// between each of the consecutive values in s.
+func seriesDeltas(s []int64) []int64 {
+	if len(s) == 0 {
+		return []int64{}
+	}
+
+	d := make([]int64, len(s))
+	d[0] = s[0]
+	for i := 1; i < len(s); i++ {
+		d[i] = s[i] - s[i-1]
+	}
+	return d
+}
+
+// varintsLength returns the number of bytes needed to encode the values in s
+// as variable length varints.
+func varintsLength(s []int64) int {
+	b := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64)
+
+	l := 0
+	for _, n := range s {
+		l += binary.PutVarint(b, n)
+	}
+	return l
+}
+
+// uvarintLength returns the number of bytes needed to encode n as a variable
+// length uvarint.
+func uvarintLength(n uint64) int {
+	l := 1
+	for n >= 0x80 {
+		n >>= 7
+		l++
+	}
+	return l
+}
+
+// uvarintBytes encodes n as a uvarint in a slice of byteLength bytes.
+func uvarintBytes(n uint64, byteLength int) []byte {
+	b := make([]byte, byteLength, byteLength)
+	_ = binary.PutUvarint(b, n)
+	return b
+}
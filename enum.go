@@ -0,0 +1,114 @@
+package coding
+
+import (
+	"reflect"
+	"sync"
+)
+
+// enumType types are the integer types registered with RegisterEnum.
+type enumType struct {
+
+	// The names of the type's values.
+	names map[int64]string
+
+	// The values of the type's names.
+	values map[string]int64
+}
+
+// enums holds the registered enum types keyed by type.
+var enums = struct {
+	sync.RWMutex
+	types map[reflect.Type]*enumType
+}{
+	types: make(map[reflect.Type]*enumType),
+}
+
+// Exported functions
+
+// RegisterEnum records the names of the values of an integer type so that its
+// values may be encoded with EncodeEnum and decoded with DecodeEnum.
+//
+// The names argument must be a map from the integer type's values to their
+// names, such as map[Color]string{Red: "red", Green: "green"}. Only the values
+// in the map may be encoded or decoded. Names should be stable, since encoders
+// may store them in place of the values.
+//
+// RegisterEnum panics if names isn't such a map, if two values share a name, or
+// if the type has already been registered.
+func RegisterEnum(names interface{}) {
+	v := reflect.ValueOf(names)
+	if v.Kind() != reflect.Map || v.Type().Elem().Kind() != reflect.String || !enumKind(v.Type().Key().Kind()) {
+		panic("coding: RegisterEnum requires a map from an integer type to strings")
+	}
+
+	en := &enumType{
+		names:  make(map[int64]string, v.Len()),
+		values: make(map[string]int64, v.Len()),
+	}
+
+	iter := v.MapRange()
+	for iter.Next() {
+		n := enumInt64(iter.Key())
+		s := iter.Value().String()
+		if _, ok := en.values[s]; ok || s == "" {
+			panic("coding: RegisterEnum of invalid or duplicate name " + s)
+		}
+
+		en.names[n] = s
+		en.values[s] = n
+	}
+
+	t := v.Type().Key()
+
+	enums.Lock()
+	defer enums.Unlock()
+
+	if _, ok := enums.types[t]; ok {
+		panic("coding: RegisterEnum of " + t.String() + " conflicts with an earlier registration")
+	}
+	enums.types[t] = en
+}
+
+// Non-exported functions
+
+// lookupEnum returns the registration of the given enum type.
+func lookupEnum(t reflect.Type) (*enumType, bool) {
+	enums.RLock()
+	defer enums.RUnlock()
+
+	en, ok := enums.types[t]
+	return en, ok
+}
+
+// enumKind returns whether or not values of the given kind can be enums.
+func enumKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8,
+		reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		return true
+	default:
+		return false
+	}
+}
+
+// enumInt64 returns the integer value of v, which must be of an enum kind.
+//
+// Unsigned values are converted bit for bit so that every value is distinct.
+func enumInt64(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		return v.Int()
+	default:
+		return int64(v.Uint())
+	}
+}
+
+// setEnumInt64 sets v, which must be of an enum kind, to the integer value n.
+func setEnumInt64(v reflect.Value, n int64) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		v.SetInt(n)
+	default:
+		v.SetUint(uint64(n))
+	}
+}
@@ -0,0 +1,100 @@
+package coding
+
+import "math"
+
+// float32ToFloat16 converts f to IEEE 754 binary16 bits, rounding to the
+// nearest value with ties to even.
+//
+// Values too large for binary16 become infinities, values too small become
+// subnormals or signed zeros, and NaNs stay NaNs.
+func float32ToFloat16(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int32(b>>23) & 0xFF
+	mant := b & 0x7FFFFF
+
+	// Infinity and NaN
+	if exp == 0xFF {
+		if mant != 0 {
+			return sign | 0x7E00 | uint16(mant>>13)
+		}
+		return sign | 0x7C00
+	}
+
+	// Rebias the exponent and check for overflow
+	e := exp - 127 + 15
+	if e >= 0x1F {
+		return sign | 0x7C00
+	}
+
+	// Subnormals and zeros
+	if e <= 0 {
+		if e < -10 {
+			return sign
+		}
+
+		m := mant | 0x800000
+		shift := uint32(14 - e)
+		return sign | uint16(roundShift(m, shift))
+	}
+
+	// Normals, where rounding may carry in to the exponent
+	return sign | uint16(roundShift(uint32(e)<<23|mant, 13))
+}
+
+// float16ToFloat32 converts IEEE 754 binary16 bits to a float32.
+func float16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1F
+	mant := uint32(h & 0x3FF)
+
+	switch exp {
+	case 0x1F:
+		return math.Float32frombits(sign | 0x7F800000 | mant<<13)
+	case 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+
+		// Normalize the subnormal
+		e := uint32(127 - 15 + 1)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		return math.Float32frombits(sign | e<<23 | (mant&0x3FF)<<13)
+	default:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+	}
+}
+
+// float32ToBFloat16 converts f to bfloat16 bits, rounding to the nearest value
+// with ties to even.
+func float32ToBFloat16(f float32) uint16 {
+	b := math.Float32bits(f)
+
+	// Keep NaNs quiet so they don't truncate to infinities
+	if b&0x7FFFFFFF > 0x7F800000 {
+		return uint16(b>>16) | 0x40
+	}
+
+	return uint16(roundShift(b, 16))
+}
+
+// bfloat16ToFloat32 converts bfloat16 bits to a float32.
+func bfloat16ToFloat32(h uint16) float32 {
+	return math.Float32frombits(uint32(h) << 16)
+}
+
+// roundShift shifts b right by shift bits, rounding to the nearest value with
+// ties to even.
+func roundShift(b uint32, shift uint32) uint32 {
+	r := b >> shift
+	rem := b & (1<<shift - 1)
+	half := uint32(1) << (shift - 1)
+
+	if rem > half || (rem == half && r&1 == 1) {
+		r++
+	}
+	return r
+}
@@ -0,0 +1,1134 @@
+package coding
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"math/big"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonMember types are the members of JSON objects.
+type jsonMember struct {
+	key   string
+	value interface{}
+}
+
+// jsonObject types are JSON objects with their members in order.
+type jsonObject []jsonMember
+
+// Exported functions
+
+// FromJSON converts JSON to encoded data.
+//
+// If annotated is true, then j must be in the form returned by ToJSON, and the
+// round trip is lossless, except that *big.Float values are decoded with an
+// exact accuracy. Otherwise, j must be an array of plain JSON values.
+// Plain values are encoded as nil values, booleans, strings, int64s if they
+// are integers and float64s if they aren't. Arrays are encoded as sections of
+// their elements, and objects as sections of their keys, each followed by its
+// value.
+//
+// ErrJSON is returned if j isn't valid JSON of the expected form, or if it
+// holds an integer that is out of the range that the encoder can store for its
+// type, such as an int64 outside of ±2^55.
+func FromJSON(j []byte, annotated bool) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.UseNumber()
+
+	v, err := readJSON(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, ErrJSON
+	}
+
+	e := NewEncoder()
+	if o, ok := v.(jsonObject); ok && annotated && len(o) == 1 && o[0].key == "indexed" {
+		e.SetIndexed(true)
+		v = o[0].value
+	}
+
+	values, ok := v.([]interface{})
+	if !ok {
+		return nil, ErrJSON
+	}
+
+	for _, x := range values {
+		if annotated {
+			err = e.encodeAnnotatedJSON(x)
+		} else {
+			err = e.encodePlainJSON(x)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e.Data(), nil
+}
+
+// Non-exported methods
+
+// encodePlainJSON encodes the plain JSON value v.
+func (e *Encoder) encodePlainJSON(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.EncodeNil()
+	case bool:
+		e.EncodeBool(v)
+	case json.Number:
+		if !strings.ContainsAny(string(v), ".eE") {
+			n, err := jsonSlotInt(v, 64, 8)
+			if err != nil {
+				return err
+			}
+			e.EncodeInt64(n)
+			return nil
+		}
+
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return ErrJSON
+		}
+		e.EncodeFloat64(f)
+	case string:
+		return e.encodeString(v)
+	case []interface{}:
+		e.BeginSection()
+		for _, x := range v {
+			if err := e.encodePlainJSON(x); err != nil {
+				return err
+			}
+		}
+		return e.EndSection()
+	case jsonObject:
+		e.BeginSection()
+		for _, m := range v {
+			if err := e.encodeString(m.key); err != nil {
+				return err
+			}
+
+			if err := e.encodePlainJSON(m.value); err != nil {
+				return err
+			}
+		}
+		return e.EndSection()
+	}
+	return nil
+}
+
+// encodeAnnotatedJSON encodes the JSON value v, which must be null or an
+// object whose only key is the type of its value.
+func (e *Encoder) encodeAnnotatedJSON(v interface{}) error {
+	if v == nil {
+		e.EncodeNil()
+		return nil
+	}
+
+	o, ok := v.(jsonObject)
+	if !ok || len(o) != 1 {
+		return ErrJSON
+	}
+
+	name, x := o[0].key, o[0].value
+	switch name {
+	case "bool":
+		b, ok := x.(bool)
+		if !ok {
+			return ErrJSON
+		}
+		e.EncodeBool(b)
+	case "int":
+		n, err := jsonSlotInt(x, strconv.IntSize, 8)
+		if err != nil {
+			return err
+		}
+		e.EncodeInt(int(n))
+	case "int64":
+		n, err := jsonSlotInt(x, 64, 8)
+		if err != nil {
+			return err
+		}
+		e.EncodeInt64(n)
+	case "int32":
+		n, err := jsonSlotInt(x, 32, 4)
+		if err != nil {
+			return err
+		}
+		e.EncodeInt32(int32(n))
+	case "int16":
+		n, err := jsonSlotInt(x, 16, 2)
+		if err != nil {
+			return err
+		}
+		e.EncodeInt16(int16(n))
+	case "int8":
+		n, err := jsonSlotInt(x, 8, 1)
+		if err != nil {
+			return err
+		}
+		e.EncodeInt8(int8(n))
+	case "uint":
+		n, err := jsonSlotUint(x, strconv.IntSize, 8)
+		if err != nil {
+			return err
+		}
+		e.EncodeUint(uint(n))
+	case "uint64":
+		n, err := jsonSlotUint(x, 64, 8)
+		if err != nil {
+			return err
+		}
+		e.EncodeUint64(n)
+	case "uint32":
+		n, err := jsonSlotUint(x, 32, 4)
+		if err != nil {
+			return err
+		}
+		e.EncodeUint32(uint32(n))
+	case "uint16":
+		n, err := jsonSlotUint(x, 16, 2)
+		if err != nil {
+			return err
+		}
+		e.EncodeUint16(uint16(n))
+	case "uint8":
+		n, err := jsonSlotUint(x, 8, 1)
+		if err != nil {
+			return err
+		}
+		e.EncodeUint8(uint8(n))
+	case "float64":
+		f, err := jsonFloat(x, 64)
+		if err != nil {
+			return err
+		}
+		e.EncodeFloat64(f)
+	case "float32":
+		f, err := jsonFloat(x, 32)
+		if err != nil {
+			return err
+		}
+		e.EncodeFloat32(float32(f))
+	case "float16":
+		f, err := jsonFloat(x, 32)
+		if err != nil {
+			return err
+		}
+		e.EncodeFloat16(float32(f))
+	case "bfloat16":
+		f, err := jsonFloat(x, 32)
+		if err != nil {
+			return err
+		}
+		e.EncodeBFloat16(float32(f))
+	case "complex128":
+		fs, err := jsonElements(codingTypeFloat64, x)
+		if err != nil || len(fs.([]float64)) != 2 {
+			return ErrJSON
+		}
+		e.EncodeComplex128(complex(fs.([]float64)[0], fs.([]float64)[1]))
+	case "complex64":
+		fs, err := jsonElements(codingTypeFloat32, x)
+		if err != nil || len(fs.([]float32)) != 2 {
+			return ErrJSON
+		}
+		e.EncodeComplex64(complex(fs.([]float32)[0], fs.([]float32)[1]))
+	case "int128":
+		n, err := jsonBigInt(x)
+		if err != nil {
+			return err
+		}
+
+		i, ok := bigToInt128(n)
+		if !ok {
+			return ErrJSON
+		}
+		e.EncodeInt128(i)
+	case "uint128":
+		n, err := jsonBigInt(x)
+		if err != nil {
+			return err
+		}
+
+		u, ok := bigToUint128(n)
+		if !ok {
+			return ErrJSON
+		}
+		e.EncodeUint128(u)
+	case "string":
+		s, ok := x.(string)
+		if !ok {
+			return ErrJSON
+		}
+		return e.encodeString(s)
+	case "bytes":
+		b, err := jsonBytes(x)
+		if err != nil {
+			return err
+		}
+		e.EncodeData(b)
+	case "[]float16", "[]bfloat16":
+		fs, err := jsonElements(codingTypeFloat32, x)
+		if err != nil {
+			return err
+		}
+
+		if name == "[]float16" {
+			e.EncodeFloat16s(fs.([]float32))
+		} else {
+			e.EncodeBFloat16s(fs.([]float32))
+		}
+	case "bitset":
+		s, ok := x.(string)
+		if !ok {
+			return ErrJSON
+		}
+
+		bs := NewBitset(len(s))
+		for i, c := range []byte(s) {
+			switch c {
+			case '1':
+				bs.Set(i)
+			case '0':
+			default:
+				return ErrJSON
+			}
+		}
+		e.EncodeBitset(bs)
+	case "tensor":
+		t, err := jsonTensor(x)
+		if err != nil {
+			return err
+		}
+		e.EncodeTensor(t)
+	case "sparsevector":
+		v, err := jsonSparseVector(x)
+		if err != nil {
+			return err
+		}
+		e.EncodeSparseVector(v)
+	case "int64series":
+		s, err := jsonElements(codingTypeInt64, x)
+		if err != nil {
+			return err
+		}
+		e.EncodeInt64Series(s.([]int64))
+	case "float64series":
+		s, err := jsonElements(codingTypeFloat64, x)
+		if err != nil {
+			return err
+		}
+		e.EncodeFloat64Series(s.([]float64))
+	case "bigint":
+		n, err := jsonBigInt(x)
+		if err != nil {
+			return err
+		}
+		e.EncodeBigInt(n)
+	case "bigrat":
+		s, ok := x.(string)
+		if !ok {
+			return ErrJSON
+		}
+
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return ErrJSON
+		}
+		e.EncodeBigRat(r)
+	case "bigfloat":
+		f, err := jsonBigFloat(x)
+		if err != nil {
+			return err
+		}
+		e.EncodeBigFloat(f)
+	case "decimal":
+		s, ok := x.(string)
+		if !ok {
+			return ErrJSON
+		}
+
+		d, err := parseJSONDecimal(s)
+		if err != nil {
+			return err
+		}
+		e.EncodeDecimal(d)
+	case "time":
+		t, err := jsonTime(x)
+		if err != nil {
+			return err
+		}
+		e.EncodeTime(t)
+	case "duration":
+		s, ok := x.(string)
+		if !ok {
+			return ErrJSON
+		}
+
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return ErrJSON
+		}
+		e.EncodeDuration(d)
+	case "uuid":
+		s, ok := x.(string)
+		if !ok {
+			return ErrJSON
+		}
+
+		u, err := ParseUUID(s)
+		if err != nil {
+			return err
+		}
+		e.EncodeUUID(u)
+	case "addr", "prefix", "addrport":
+		return e.encodeJSONNetwork(name, x)
+	case "url":
+		s, ok := x.(string)
+		if !ok {
+			return ErrJSON
+		}
+
+		u, err := url.Parse(s)
+		if err != nil {
+			return ErrValue
+		}
+		e.EncodeURL(u)
+	case "enum":
+		switch x := x.(type) {
+		case json.Number:
+			n, err := jsonInt(x, 64)
+			if err != nil {
+				return err
+			}
+
+			e.appendType(codingTypeEnum)
+			e.appendByte(enumOrdinal)
+			e.appendVarint(n)
+		case string:
+			e.appendType(codingTypeEnum)
+			e.appendByte(enumName)
+			e.appendString(x)
+		default:
+			return ErrJSON
+		}
+	case "set":
+		o, ok := x.(jsonObject)
+		if !ok || len(o) != 1 {
+			return ErrJSON
+		}
+
+		var s interface{}
+		if o[0].key == "[]string" {
+			ss, err := jsonStrings(o[0].value)
+			if err != nil {
+				return err
+			}
+			s = ss
+		} else {
+			kind, ok := jsonElementKind(strings.TrimPrefix(o[0].key, "[]"))
+			if !ok || !strings.HasPrefix(o[0].key, "[]") {
+				return ErrJSON
+			}
+
+			var err error
+			if s, err = jsonElements(kind, o[0].value); err != nil {
+				return err
+			}
+		}
+		return e.EncodeSet(s)
+	case "map", "sortedmap":
+		return e.encodeJSONMap(name == "sortedmap", x)
+	case "section":
+		values, ok := x.([]interface{})
+		if !ok {
+			return ErrJSON
+		}
+
+		e.BeginSection()
+		for _, v := range values {
+			if err := e.encodeAnnotatedJSON(v); err != nil {
+				return err
+			}
+		}
+		return e.EndSection()
+	case "record":
+		return e.encodeJSONRecord(x)
+	case "any":
+		return e.encodeJSONAny(codingTypeAny, x)
+	case "anydef":
+		return e.encodeJSONAny(codingTypeAnyDef, x)
+	case "anyref":
+		n, err := jsonUint(x, 64)
+		if err != nil {
+			return err
+		}
+
+		if n >= uint64(len(e.jsonDefs)) {
+			return ErrJSON
+		}
+
+		// References store the distance back to their definition in the data
+		// that is being encoded
+		r := len(e.data)
+		e.appendType(codingTypeAnyRef)
+		e.appendUvarint(uint64(r - e.jsonDefs[n]))
+	default:
+		return e.encodeJSONElements(name, x)
+	}
+	return nil
+}
+
+// encodeJSONElements encodes the packed slice or fixed-size array x with the
+// given annotated type name, such as "[]int32" or "[32]uint8".
+func (e *Encoder) encodeJSONElements(name string, x interface{}) error {
+	i := strings.IndexByte(name, ']')
+	if !strings.HasPrefix(name, "[") || i < 0 {
+		return ErrJSON
+	}
+
+	kind, ok := jsonElementKind(name[i+1:])
+	if !ok {
+		return ErrJSON
+	}
+
+	s, err := jsonElements(kind, x)
+	if err != nil {
+		return err
+	}
+
+	_, n, _ := elementKind(s)
+	if i == 1 {
+		e.appendSlice(kind, s)
+		return nil
+	}
+
+	if l, err := strconv.Atoi(name[1:i]); err != nil || l != n {
+		return ErrJSON
+	}
+
+	e.appendType(codingTypeArray)
+	e.appendByte(kind)
+	e.appendUvarint(uint64(n))
+	e.appendElements(s, n*elementSize(kind))
+	return nil
+}
+
+// encodeJSONNetwork encodes the network value x with the given annotated type
+// name. Empty strings are encoded as zero values.
+func (e *Encoder) encodeJSONNetwork(name string, x interface{}) error {
+	s, ok := x.(string)
+	if !ok {
+		return ErrJSON
+	}
+
+	var err error
+	switch name {
+	case "addr":
+		var a netip.Addr
+		if s != "" {
+			a, err = netip.ParseAddr(s)
+		}
+		e.EncodeAddr(a)
+	case "prefix":
+		var p netip.Prefix
+		if s != "" {
+			p, err = netip.ParsePrefix(s)
+		}
+		e.EncodePrefix(p)
+	case "addrport":
+		var ap netip.AddrPort
+		if s != "" {
+			ap, err = netip.ParseAddrPort(s)
+		}
+		e.EncodeAddrPort(ap)
+	}
+
+	if err != nil {
+		return ErrValue
+	}
+	return nil
+}
+
+// encodeJSONMap encodes the annotated map entries x, which must be an array of
+// key and value pairs.
+//
+// ErrMap is returned if the map has duplicate keys, or if it is sorted and its
+// keys aren't in ascending order.
+func (e *Encoder) encodeJSONMap(sorted bool, x interface{}) error {
+	entries, ok := x.([]interface{})
+	if !ok {
+		return ErrJSON
+	}
+
+	e.appendType(codingTypeMap)
+	if sorted {
+		e.appendByte(mapSorted)
+	} else {
+		e.appendByte(mapInsertionOrder)
+	}
+	e.appendUvarint(uint64(len(entries)))
+
+	keys := make(map[string]bool, len(entries))
+	prev := ""
+	for i, entry := range entries {
+		pair, ok := entry.([]interface{})
+		if !ok || len(pair) != 2 {
+			return ErrJSON
+		}
+
+		k, ok := pair[0].(string)
+		if !ok {
+			return ErrJSON
+		}
+
+		if keys[k] || (sorted && i > 0 && k < prev) {
+			return ErrMap
+		}
+		keys[k], prev = true, k
+
+		e.appendString(k)
+		if err := e.encodeAnnotatedJSON(pair[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeJSONRecord encodes the annotated record fields x, which must be an
+// array of field number and value array pairs.
+func (e *Encoder) encodeJSONRecord(x interface{}) error {
+	fields, ok := x.([]interface{})
+	if !ok {
+		return ErrJSON
+	}
+
+	e.BeginRecord()
+	for _, field := range fields {
+		pair, ok := field.([]interface{})
+		if !ok || len(pair) != 2 {
+			return ErrJSON
+		}
+
+		n, err := jsonInt(pair[0], 32)
+		if err != nil {
+			return err
+		}
+
+		values, ok := pair[1].([]interface{})
+		if !ok {
+			return ErrJSON
+		}
+
+		if err := e.Field(int(n)); err != nil {
+			return err
+		}
+
+		for _, v := range values {
+			if err := e.encodeAnnotatedJSON(v); err != nil {
+				return err
+			}
+		}
+	}
+	return e.EndRecord()
+}
+
+// encodeJSONAny encodes the annotated registered value x as a value of type
+// t. The value's type ID is used rather than its type's name, so its type
+// doesn't need to be registered.
+func (e *Encoder) encodeJSONAny(t byte, x interface{}) error {
+	o, ok := x.(jsonObject)
+	if !ok {
+		return ErrJSON
+	}
+
+	idv, _ := o.get("id")
+	id, err := jsonUint(idv, 32)
+	if err != nil {
+		return err
+	}
+
+	vs, _ := o.get("values")
+	values, ok := vs.([]interface{})
+	if !ok {
+		return ErrJSON
+	}
+
+	if t == codingTypeAnyDef {
+		e.jsonDefs = append(e.jsonDefs, len(e.data))
+	}
+
+	e.beginBlock(t)
+	b := make([]byte, 4, 4)
+	binary.LittleEndian.PutUint32(b, uint32(id))
+	e.appendBytes(b)
+
+	for _, v := range values {
+		if err := e.encodeAnnotatedJSON(v); err != nil {
+			return err
+		}
+	}
+
+	e.endBlock()
+	return nil
+}
+
+// get returns the value of the object's member with the given key.
+func (o jsonObject) get(key string) (interface{}, bool) {
+	for _, m := range o {
+		if m.key == key {
+			return m.value, true
+		}
+	}
+	return nil, false
+}
+
+// Non-exported functions
+
+// readJSON reads the next JSON value from dec, keeping the order of the
+// members of objects.
+//
+// Values are returned as nil, bools, json.Numbers, strings, []interface{}s and
+// jsonObjects.
+func readJSON(dec *json.Decoder) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, ErrJSON
+	}
+
+	switch t {
+	case json.Delim('['):
+		a := []interface{}{}
+		for dec.More() {
+			v, err := readJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return nil, ErrJSON
+		}
+		return a, nil
+	case json.Delim('{'):
+		o := jsonObject{}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, ErrJSON
+			}
+
+			v, err := readJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, jsonMember{key: k.(string), value: v})
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return nil, ErrJSON
+		}
+		return o, nil
+	default:
+		return t, nil
+	}
+}
+
+// jsonTime returns the time in the JSON object x written by appendJSONTime.
+//
+// Like DecodeTime, if the time's location can't be loaded, then the time is
+// returned in a fixed zone with the location's name and the time's offset.
+func jsonTime(x interface{}) (time.Time, error) {
+	o, ok := x.(jsonObject)
+	if !ok {
+		return time.Time{}, ErrJSON
+	}
+
+	secv, _ := o.get("sec")
+	sec, err := jsonInt(secv, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	nsecv, _ := o.get("nsec")
+	nsec, err := jsonInt(nsecv, 64)
+	if err != nil || nsec < 0 || nsec >= int64(time.Second) {
+		return time.Time{}, ErrJSON
+	}
+
+	t := time.Unix(sec, nsec)
+	if len(o) == 2 {
+		return t.UTC(), nil
+	}
+
+	offsetv, _ := o.get("offset")
+	offset, err := jsonInt(offsetv, 32)
+	if err != nil || len(o) != 4 {
+		return time.Time{}, ErrJSON
+	}
+
+	if name, ok := o.get("zone"); ok {
+		if name, ok := name.(string); ok {
+			return t.In(time.FixedZone(name, int(offset))), nil
+		}
+	} else if name, ok := o.get("location"); ok {
+		if name, ok := name.(string); ok {
+			if l, err := time.LoadLocation(name); err == nil {
+				return t.In(l), nil
+			}
+			return t.In(time.FixedZone(name, int(offset))), nil
+		}
+	}
+	return time.Time{}, ErrJSON
+}
+
+// parseJSONDecimal parses a decimal such as "12.3450", or "12e3" for decimals
+// with negative scales.
+func parseJSONDecimal(s string) (Decimal, error) {
+	i := strings.IndexByte(s, 'e')
+	if i < 0 {
+		return ParseDecimal(s)
+	}
+
+	unscaled, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil {
+		return Decimal{}, ErrDecimal
+	}
+
+	exp, err := strconv.ParseInt(s[i+1:], 10, 32)
+	if err != nil || exp <= 0 || exp > math.MaxInt32 {
+		return Decimal{}, ErrDecimal
+	}
+	return NewDecimal(unscaled, int32(-exp)), nil
+}
+
+// bigToInt128 returns x as a 128-bit integer, or false if it doesn't fit.
+func bigToInt128(x *big.Int) (Int128, bool) {
+	hi := new(big.Int).Rsh(x, 64)
+	if !hi.IsInt64() {
+		return Int128{}, false
+	}
+
+	lo := new(big.Int).And(x, new(big.Int).SetUint64(math.MaxUint64))
+	return Int128{Hi: hi.Int64(), Lo: lo.Uint64()}, true
+}
+
+// bigToUint128 returns x as an unsigned 128-bit integer, or false if it
+// doesn't fit.
+func bigToUint128(x *big.Int) (Uint128, bool) {
+	if x.Sign() < 0 || x.BitLen() > 128 {
+		return Uint128{}, false
+	}
+
+	hi := new(big.Int).Rsh(x, 64)
+	lo := new(big.Int).And(x, new(big.Int).SetUint64(math.MaxUint64))
+	return Uint128{Hi: hi.Uint64(), Lo: lo.Uint64()}, true
+}
+
+// jsonInt returns the JSON number v as an integer that fits in bitSize bits.
+func jsonInt(v interface{}, bitSize int) (int64, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, ErrJSON
+	}
+
+	i, err := strconv.ParseInt(string(n), 10, bitSize)
+	if err != nil {
+		return 0, ErrJSON
+	}
+	return i, nil
+}
+
+// jsonUint returns the JSON number v as an unsigned integer that fits in
+// bitSize bits.
+func jsonUint(v interface{}, bitSize int) (uint64, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, ErrJSON
+	}
+
+	u, err := strconv.ParseUint(string(n), 10, bitSize)
+	if err != nil {
+		return 0, ErrJSON
+	}
+	return u, nil
+}
+
+// jsonSlotInt returns the JSON number v as an integer that fits in bitSize bits
+// and whose varint fits in the l byte slot that the encoder stores it in.
+func jsonSlotInt(v interface{}, bitSize int, l int) (int64, error) {
+	n, err := jsonInt(v, bitSize)
+	if err != nil {
+		return 0, err
+	}
+
+	if uvarintLength(uint64(n)<<1^uint64(n>>63)) > l {
+		return 0, ErrJSON
+	}
+	return n, nil
+}
+
+// jsonSlotUint returns the JSON number v as an unsigned integer that fits in
+// bitSize bits and whose uvarint fits in the l byte slot that the encoder
+// stores it in.
+func jsonSlotUint(v interface{}, bitSize int, l int) (uint64, error) {
+	u, err := jsonUint(v, bitSize)
+	if err != nil {
+		return 0, err
+	}
+
+	if uvarintLength(u) > l {
+		return 0, ErrJSON
+	}
+	return u, nil
+}
+
+// jsonFloat returns the JSON number v, or the JSON string "NaN", "+Inf" or
+// "-Inf", as a float with the given bit size.
+func jsonFloat(v interface{}, bitSize int) (float64, error) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), bitSize)
+		if err != nil {
+			return 0, ErrJSON
+		}
+		return f, nil
+	case string:
+		switch v {
+		case "NaN":
+			return math.NaN(), nil
+		case "+Inf":
+			return math.Inf(1), nil
+		case "-Inf":
+			return math.Inf(-1), nil
+		}
+	}
+	return 0, ErrJSON
+}
+
+// jsonBytes returns the base64 JSON string v as bytes.
+func jsonBytes(v interface{}) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, ErrJSON
+	}
+
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrJSON
+	}
+	return b, nil
+}
+
+// jsonBigInt returns the JSON string v as an arbitrary precision integer.
+func jsonBigInt(v interface{}) (*big.Int, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, ErrJSON
+	}
+
+	x, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, ErrJSON
+	}
+	return x, nil
+}
+
+// jsonBigFloat returns the JSON object v, holding a float's exact value in
+// binary exponent form, its precision and rounding mode, as an arbitrary
+// precision float.
+func jsonBigFloat(v interface{}) (*big.Float, error) {
+	o, ok := v.(jsonObject)
+	if !ok {
+		return nil, ErrJSON
+	}
+
+	pv, _ := o.get("prec")
+	prec, err := jsonUint(pv, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	mv, _ := o.get("mode")
+	ms, _ := mv.(string)
+	mode := big.RoundingMode(0)
+	for mode <= big.ToPositiveInf && mode.String() != ms {
+		mode++
+	}
+
+	if mode > big.ToPositiveInf {
+		return nil, ErrJSON
+	}
+
+	vv, _ := o.get("value")
+	s, ok := vv.(string)
+	if !ok {
+		return nil, ErrJSON
+	}
+
+	// A zero precision is replaced when the value is parsed, so it is set
+	// afterwards
+	x := new(big.Float).SetMode(mode).SetPrec(uint(prec))
+	if prec == 0 {
+		x.SetPrec(64)
+	}
+
+	if _, ok := x.SetString(s); !ok {
+		return nil, ErrJSON
+	}
+	return x.SetPrec(uint(prec)), nil
+}
+
+// jsonStrings returns the JSON array of strings v as a []string.
+func jsonStrings(v interface{}) ([]string, error) {
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil, ErrJSON
+	}
+
+	ss := make([]string, len(a))
+	for i, x := range a {
+		if ss[i], ok = x.(string); !ok {
+			return nil, ErrJSON
+		}
+	}
+	return ss, nil
+}
+
+// jsonElementKind returns the element kind with the given JSON type name.
+func jsonElementKind(name string) (byte, bool) {
+	for kind, n := range elementNames {
+		if n == name {
+			return kind, true
+		}
+	}
+	return 0, false
+}
+
+// jsonElements returns the JSON array of numbers v, or the base64 JSON string
+// v if kind is uint8, as a numeric slice of the given kind.
+func jsonElements(kind byte, v interface{}) (interface{}, error) {
+	if kind == codingTypeUint8 {
+		return jsonBytes(v)
+	}
+
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil, ErrJSON
+	}
+
+	s := makeElements(kind, len(a))
+	sv := reflect.ValueOf(s)
+	bitSize := 8 * elementSize(kind)
+	for i, x := range a {
+		switch e := sv.Index(i); {
+		case e.CanInt():
+			n, err := jsonInt(x, bitSize)
+			if err != nil {
+				return nil, err
+			}
+			e.SetInt(n)
+		case e.CanUint():
+			n, err := jsonUint(x, bitSize)
+			if err != nil {
+				return nil, err
+			}
+			e.SetUint(n)
+		default:
+			f, err := jsonFloat(x, bitSize)
+			if err != nil {
+				return nil, err
+			}
+			e.SetFloat(f)
+		}
+	}
+	return s, nil
+}
+
+// jsonTypedElements returns the JSON object v, whose only key is the type name
+// of a numeric slice such as "[]float32", as a numeric slice.
+func jsonTypedElements(v interface{}) (interface{}, error) {
+	o, ok := v.(jsonObject)
+	if !ok || len(o) != 1 || !strings.HasPrefix(o[0].key, "[]") {
+		return nil, ErrJSON
+	}
+
+	kind, ok := jsonElementKind(o[0].key[2:])
+	if !ok {
+		return nil, ErrJSON
+	}
+	return jsonElements(kind, o[0].value)
+}
+
+// jsonTensor returns the JSON object v, holding a tensor's shape and data, as
+// a tensor.
+func jsonTensor(v interface{}) (*Tensor, error) {
+	o, ok := v.(jsonObject)
+	if !ok {
+		return nil, ErrJSON
+	}
+
+	sv, _ := o.get("shape")
+	shape, err := jsonElements(codingTypeInt64, sv)
+	if err != nil {
+		return nil, err
+	}
+
+	dv, _ := o.get("data")
+	data, err := jsonTypedElements(dv)
+	if err != nil {
+		return nil, err
+	}
+
+	s := make([]int, len(shape.([]int64)))
+	for i, n := range shape.([]int64) {
+		s[i] = int(n)
+	}
+	return NewTensor(s, data)
+}
+
+// jsonSparseVector returns the JSON object v, holding a sparse vector's length,
+// indices and values, as a sparse vector.
+func jsonSparseVector(v interface{}) (*SparseVector, error) {
+	o, ok := v.(jsonObject)
+	if !ok {
+		return nil, ErrJSON
+	}
+
+	lv, _ := o.get("length")
+	length, err := jsonInt(lv, strconv.IntSize)
+	if err != nil {
+		return nil, err
+	}
+
+	iv, _ := o.get("indices")
+	indices, err := jsonElements(codingTypeInt64, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	vv, _ := o.get("values")
+	values, err := jsonTypedElements(vv)
+	if err != nil {
+		return nil, err
+	}
+
+	is := make([]int, len(indices.([]int64)))
+	for i, n := range indices.([]int64) {
+		is[i] = int(n)
+	}
+	return NewSparseVector(int(length), is, values)
+}
@@ -1,3 +1,3 @@
 module github.com/colinc86/coding
 
-go 1.16
+go 1.18
@@ -0,0 +1,23 @@
+package coding
+
+// Int128 types are signed 128-bit integers made of a high and a low 64-bit
+// half in two's complement.
+type Int128 struct {
+
+	// The most significant 64 bits.
+	Hi int64
+
+	// The least significant 64 bits.
+	Lo uint64
+}
+
+// Uint128 types are unsigned 128-bit integers made of a high and a low 64-bit
+// half.
+type Uint128 struct {
+
+	// The most significant 64 bits.
+	Hi uint64
+
+	// The least significant 64 bits.
+	Lo uint64
+}
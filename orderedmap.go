@@ -0,0 +1,68 @@
+package coding
+
+// OrderedMap types are maps from strings to values that remember the order that
+// their keys were added in.
+//
+// Values must be nil or of types registered with Register, since they are
+// encoded with EncodeAny.
+type OrderedMap struct {
+
+	// The map's keys in the order that they were added.
+	keys []string
+
+	// The map's values keyed by their keys.
+	values map[string]interface{}
+}
+
+// Initializers
+
+// NewOrderedMap creates a new, empty ordered map.
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{
+		values: make(map[string]interface{}),
+	}
+}
+
+// Exported methods
+
+// Len returns the number of keys in the map.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns the map's keys in the order that they were added.
+func (m *OrderedMap) Keys() []string {
+	return append([]string{}, m.keys...)
+}
+
+// Get returns the value of key and whether or not the map contains key.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Set sets the value of key.
+//
+// If the map already contains key, then its value is replaced and it keeps its
+// position. Otherwise, key is added after the map's other keys.
+func (m *OrderedMap) Set(key string, v interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = v
+}
+
+// Delete removes key from the map.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package coding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+// littleEndian is whether or not the host stores numbers in little endian byte
+// order, in which case packed elements can be copied directly to and from
+// memory.
+var littleEndian = func() bool {
+	n := uint16(1)
+	return *(*byte)(unsafe.Pointer(&n)) == 1
+}()
+
+// elementSize returns the number of bytes in a packed element of the given
+// kind, or zero if the kind can't be packed.
+//
+// Element kinds are the coding types of the fixed-width numeric types.
+func elementSize(kind byte) int {
+	switch kind {
+	case codingTypeFloat64, codingTypeInt64, codingTypeUint64:
+		return 8
+	case codingTypeFloat32, codingTypeInt32, codingTypeUint32:
+		return 4
+	case codingTypeInt16, codingTypeUint16:
+		return 2
+	case codingTypeInt8, codingTypeUint8:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// elementKind returns the element kind and length of the numeric slice s.
+//
+// If s isn't a slice of a fixed-width numeric type, then false is returned.
+func elementKind(s interface{}) (byte, int, bool) {
+	switch s := s.(type) {
+	case []float64:
+		return codingTypeFloat64, len(s), true
+	case []float32:
+		return codingTypeFloat32, len(s), true
+	case []int64:
+		return codingTypeInt64, len(s), true
+	case []int32:
+		return codingTypeInt32, len(s), true
+	case []int16:
+		return codingTypeInt16, len(s), true
+	case []int8:
+		return codingTypeInt8, len(s), true
+	case []uint64:
+		return codingTypeUint64, len(s), true
+	case []uint32:
+		return codingTypeUint32, len(s), true
+	case []uint16:
+		return codingTypeUint16, len(s), true
+	case []uint8:
+		return codingTypeUint8, len(s), true
+	default:
+		return 0, 0, false
+	}
+}
+
+// makeElements makes a numeric slice of the given kind and length.
+func makeElements(kind byte, n int) interface{} {
+	switch kind {
+	case codingTypeFloat64:
+		return make([]float64, n)
+	case codingTypeFloat32:
+		return make([]float32, n)
+	case codingTypeInt64:
+		return make([]int64, n)
+	case codingTypeInt32:
+		return make([]int32, n)
+	case codingTypeInt16:
+		return make([]int16, n)
+	case codingTypeInt8:
+		return make([]int8, n)
+	case codingTypeUint64:
+		return make([]uint64, n)
+	case codingTypeUint32:
+		return make([]uint32, n)
+	case codingTypeUint16:
+		return make([]uint16, n)
+	case codingTypeUint8:
+		return make([]uint8, n)
+	default:
+		return nil
+	}
+}
+
+// elementBytes returns the memory of the numeric slice s as a byte slice.
+func elementBytes(s interface{}) []byte {
+	kind, n, ok := elementKind(s)
+	if !ok || n == 0 {
+		return nil
+	}
+
+	return unsafe.Slice((*byte)(reflect.ValueOf(s).UnsafePointer()), n*elementSize(kind))
+}
+
+// packElements packs the elements of the numeric slice s in to b in little
+// endian byte order.
+//
+// b must be long enough to hold all of the elements of s.
+func packElements(b []byte, s interface{}) {
+	if littleEndian {
+		copy(b, elementBytes(s))
+		return
+	}
+
+	switch s := s.(type) {
+	case []float64:
+		for i, v := range s {
+			binary.LittleEndian.PutUint64(b[8*i:], math.Float64bits(v))
+		}
+	case []float32:
+		for i, v := range s {
+			binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(v))
+		}
+	case []int64:
+		for i, v := range s {
+			binary.LittleEndian.PutUint64(b[8*i:], uint64(v))
+		}
+	case []int32:
+		for i, v := range s {
+			binary.LittleEndian.PutUint32(b[4*i:], uint32(v))
+		}
+	case []int16:
+		for i, v := range s {
+			binary.LittleEndian.PutUint16(b[2*i:], uint16(v))
+		}
+	case []int8:
+		for i, v := range s {
+			b[i] = byte(v)
+		}
+	case []uint64:
+		for i, v := range s {
+			binary.LittleEndian.PutUint64(b[8*i:], v)
+		}
+	case []uint32:
+		for i, v := range s {
+			binary.LittleEndian.PutUint32(b[4*i:], v)
+		}
+	case []uint16:
+		for i, v := range s {
+			binary.LittleEndian.PutUint16(b[2*i:], v)
+		}
+	case []uint8:
+		copy(b, s)
+	}
+}
+
+// unpackElements unpacks little endian elements from b in to the numeric slice
+// s.
+//
+// b must hold at least as many elements as s.
+func unpackElements(s interface{}, b []byte) {
+	if littleEndian {
+		copy(elementBytes(s), b)
+		return
+	}
+
+	switch s := s.(type) {
+	case []float64:
+		for i := range s {
+			s[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[8*i:]))
+		}
+	case []float32:
+		for i := range s {
+			s[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+		}
+	case []int64:
+		for i := range s {
+			s[i] = int64(binary.LittleEndian.Uint64(b[8*i:]))
+		}
+	case []int32:
+		for i := range s {
+			s[i] = int32(binary.LittleEndian.Uint32(b[4*i:]))
+		}
+	case []int16:
+		for i := range s {
+			s[i] = int16(binary.LittleEndian.Uint16(b[2*i:]))
+		}
+	case []int8:
+		for i := range s {
+			s[i] = int8(b[i])
+		}
+	case []uint64:
+		for i := range s {
+			s[i] = binary.LittleEndian.Uint64(b[8*i:])
+		}
+	case []uint32:
+		for i := range s {
+			s[i] = binary.LittleEndian.Uint32(b[4*i:])
+		}
+	case []uint16:
+		for i := range s {
+			s[i] = binary.LittleEndian.Uint16(b[2*i:])
+		}
+	case []uint8:
+		copy(s, b)
+	}
+}
+
+// elementRuns returns the lengths of the runs of equal elements in the packed
+// elements b, where each element is size bytes long.
+//
+// If storing each run's length and element wouldn't be smaller than b, then
+// false is returned.
+func elementRuns(b []byte, size int) ([]int, bool) {
+	// Count the runs first so that slices without many runs are cheap to check
+	c, l := 0, 0
+	start := 0
+	for i := size; i <= len(b); i += size {
+		if i < len(b) && elementsEqual(b, i-size, i, size) {
+			continue
+		}
+
+		c++
+		l += uvarintLength(uint64((i-start)/size)) + size
+		start = i
+
+		if l >= len(b) {
+			return nil, false
+		}
+	}
+
+	if c == 0 || uvarintLength(uint64(c))+l >= len(b) {
+		return nil, false
+	}
+
+	runs := make([]int, 0, c)
+	start = 0
+	for i := size; i <= len(b); i += size {
+		if i < len(b) && elementsEqual(b, i-size, i, size) {
+			continue
+		}
+
+		runs = append(runs, (i-start)/size)
+		start = i
+	}
+	return runs, true
+}
+
+// elementsEqual returns whether or not the packed elements at offsets i and j
+// in b are equal, where each element is size bytes long.
+func elementsEqual(b []byte, i int, j int, size int) bool {
+	switch size {
+	case 8:
+		return binary.LittleEndian.Uint64(b[i:]) == binary.LittleEndian.Uint64(b[j:])
+	case 4:
+		return binary.LittleEndian.Uint32(b[i:]) == binary.LittleEndian.Uint32(b[j:])
+	case 2:
+		return binary.LittleEndian.Uint16(b[i:]) == binary.LittleEndian.Uint16(b[j:])
+	case 1:
+		return b[i] == b[j]
+	default:
+		return bytes.Equal(b[i:i+size], b[j:j+size])
+	}
+}
+
+// arrayElements returns a slice of the elements of a, which is an array or a
+// pointer to an array, along with the slice's element kind.
+//
+// If a isn't a pointer, then its elements are copied. If a isn't an array of a
+// fixed-width numeric type, then false is returned.
+func arrayElements(a interface{}) (interface{}, byte, bool) {
+	v := reflect.ValueOf(a)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	} else if v.Kind() == reflect.Array {
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		v = c
+	}
+
+	if v.Kind() != reflect.Array {
+		return nil, 0, false
+	}
+
+	s := v.Slice(0, v.Len()).Interface()
+	kind, _, ok := elementKind(s)
+	return s, kind, ok
+}
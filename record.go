@@ -0,0 +1,60 @@
+package coding
+
+import "sort"
+
+// Record types hold the numbered fields of a decoded record.
+type Record struct {
+
+	// The decoder limited to the record's fields.
+	decoder *Decoder
+
+	// The bounds of the record's fields keyed by field number.
+	fields map[int]recordField
+}
+
+// recordField types are the bounds of a record field's values.
+type recordField struct {
+	start int
+	end   int
+}
+
+// Exported methods
+
+// Field returns a new decoder limited to the values of the field numbered n.
+//
+// If the record doesn't contain the field, then ErrMissingField is returned.
+func (r *Record) Field(n int) (*Decoder, error) {
+	f, ok := r.fields[n]
+	if !ok {
+		return nil, ErrMissingField
+	}
+	return r.decoder.subDecoder(f.start, f.end), nil
+}
+
+// Has returns whether or not the record contains the field numbered n.
+func (r *Record) Has(n int) bool {
+	_, ok := r.fields[n]
+	return ok
+}
+
+// Fields returns the record's field numbers in ascending order.
+func (r *Record) Fields() []int {
+	ns := make([]int, 0, len(r.fields))
+	for n := range r.fields {
+		ns = append(ns, n)
+	}
+
+	sort.Ints(ns)
+	return ns
+}
+
+// Missing returns the field numbers in ns that the record doesn't contain.
+func (r *Record) Missing(ns ...int) []int {
+	var m []int
+	for _, n := range ns {
+		if !r.Has(n) {
+			m = append(m, n)
+		}
+	}
+	return m
+}
@@ -0,0 +1,387 @@
+package coding
+
+import (
+	"hash/fnv"
+	"math/big"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Codable is implemented by types that encode and decode their own values.
+//
+// Types that implement Codable, or whose pointer types implement Codable, may
+// be registered with Register and encoded with EncodeAny.
+type Codable interface {
+
+	// EncodeTo encodes the value with the encoder.
+	EncodeTo(e *Encoder) error
+
+	// DecodeFrom decodes the value from the decoder.
+	DecodeFrom(d *Decoder) error
+}
+
+// registeredType types are the types registered with Register.
+type registeredType struct {
+
+	// The type's registered name.
+	name string
+
+	// The type's ID, which is derived from its name.
+	id uint32
+
+	// The registered type.
+	t reflect.Type
+}
+
+// registry holds the registered types keyed by ID and by type.
+var registry = struct {
+	sync.RWMutex
+	ids   map[uint32]*registeredType
+	types map[reflect.Type]*registeredType
+}{
+	ids:   make(map[uint32]*registeredType),
+	types: make(map[reflect.Type]*registeredType),
+}
+
+// codableType is the reflected Codable interface type.
+var codableType = reflect.TypeOf((*Codable)(nil)).Elem()
+
+// Exported functions
+
+// Register records the concrete type of proto under the given name so that its
+// values may be encoded with EncodeAny and decoded with DecodeAny.
+//
+// The type must be one of the types supported by the encoder, such as int,
+// string or time.Time, a type whose underlying type is a boolean, number or
+// string, or a type that implements Codable. Unlike gob, Register doesn't
+// encode structs, maps or slices other than []byte by reflection, so they must
+// implement Codable. Values of the type are encoded with a compact type ID derived from name, so
+// decoders must register the same type under the same name.
+//
+// Register panics if the type isn't supported, or if the name or type has
+// already been registered with a different type or name.
+func Register(name string, proto interface{}) {
+	t := reflect.TypeOf(proto)
+	if name == "" || t == nil {
+		panic("coding: Register requires a name and a non-nil value")
+	}
+
+	if !supportedType(t) {
+		panic("coding: Register of unsupported type " + t.String())
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	id := h.Sum32()
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if r, ok := registry.ids[id]; ok {
+		if r.name == name && r.t == t {
+			return
+		}
+		panic("coding: Register of " + name + " conflicts with " + r.name)
+	}
+
+	if r, ok := registry.types[t]; ok {
+		panic("coding: Register of " + t.String() + " conflicts with " + r.name)
+	}
+
+	r := &registeredType{
+		name: name,
+		id:   id,
+		t:    t,
+	}
+	registry.ids[id] = r
+	registry.types[t] = r
+}
+
+// Non-exported functions
+
+// lookupID returns the type registered with the given ID.
+func lookupID(id uint32) (*registeredType, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	r, ok := registry.ids[id]
+	return r, ok
+}
+
+// lookupType returns the registration of the given type.
+func lookupType(t reflect.Type) (*registeredType, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	r, ok := registry.types[t]
+	return r, ok
+}
+
+// supportedType returns whether or not values of type t can be encoded with
+// EncodeAny.
+func supportedType(t reflect.Type) bool {
+	if t.Implements(codableType) || reflect.PtrTo(t).Implements(codableType) {
+		return true
+	}
+
+	if nativeTypes[t] {
+		return true
+	}
+
+	if t.Kind() == reflect.Array {
+		_, _, ok := arrayElements(reflect.New(t).Interface())
+		return ok
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8,
+		reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8,
+		reflect.Float64, reflect.Float32, reflect.Complex128, reflect.Complex64:
+		return true
+	default:
+		return false
+	}
+}
+
+// nativeTypes are the types, other than booleans, numbers and strings, that
+// the encoder supports directly.
+var nativeTypes = map[reflect.Type]bool{
+	reflect.TypeOf(time.Time{}):          true,
+	reflect.TypeOf(time.Duration(0)):     true,
+	reflect.TypeOf(Decimal{}):            true,
+	reflect.TypeOf(Int128{}):             true,
+	reflect.TypeOf(Uint128{}):            true,
+	reflect.TypeOf(UUID{}):               true,
+	reflect.TypeOf(netip.Addr{}):         true,
+	reflect.TypeOf(netip.Prefix{}):       true,
+	reflect.TypeOf(netip.AddrPort{}):     true,
+	reflect.TypeOf((*url.URL)(nil)):      true,
+	reflect.TypeOf((*big.Int)(nil)):      true,
+	reflect.TypeOf((*big.Rat)(nil)):      true,
+	reflect.TypeOf((*big.Float)(nil)):    true,
+	reflect.TypeOf([]byte(nil)):          true,
+	reflect.TypeOf((*Tensor)(nil)):       true,
+	reflect.TypeOf((*Bitset)(nil)):       true,
+	reflect.TypeOf((*SparseVector)(nil)): true,
+}
+
+// Non-exported methods
+
+// encodeValue encodes v, which must be of a type that supportedType accepts.
+func (e *Encoder) encodeValue(v reflect.Value) error {
+	t := v.Type()
+	if t.Implements(codableType) {
+		return v.Interface().(Codable).EncodeTo(e)
+	}
+
+	if reflect.PtrTo(t).Implements(codableType) {
+		p := reflect.New(t)
+		p.Elem().Set(v)
+		return p.Interface().(Codable).EncodeTo(e)
+	}
+
+	if nativeTypes[t] {
+		switch x := v.Interface().(type) {
+		case time.Time:
+			e.EncodeTime(x)
+		case time.Duration:
+			e.EncodeDuration(x)
+		case Decimal:
+			e.EncodeDecimal(x)
+		case Int128:
+			e.EncodeInt128(x)
+		case Uint128:
+			e.EncodeUint128(x)
+		case UUID:
+			e.EncodeUUID(x)
+		case netip.Addr:
+			e.EncodeAddr(x)
+		case netip.Prefix:
+			e.EncodePrefix(x)
+		case netip.AddrPort:
+			e.EncodeAddrPort(x)
+		case *url.URL:
+			e.EncodeURL(x)
+		case *big.Int:
+			e.EncodeBigInt(x)
+		case *big.Rat:
+			e.EncodeBigRat(x)
+		case *big.Float:
+			e.EncodeBigFloat(x)
+		case []byte:
+			// Nil slices stay distinct from empty slices
+			if x == nil {
+				e.EncodeNil()
+			} else {
+				e.EncodeData(x)
+			}
+		case *Tensor:
+			e.EncodeTensor(x)
+		case *Bitset:
+			e.EncodeBitset(x)
+		case *SparseVector:
+			e.EncodeSparseVector(x)
+		}
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Array:
+		return e.EncodeArray(v.Interface())
+	case reflect.Bool:
+		e.EncodeBool(v.Bool())
+	case reflect.Int:
+		e.EncodeInt(int(v.Int()))
+	case reflect.Int64:
+		e.EncodeInt64(v.Int())
+	case reflect.Int32:
+		e.EncodeInt32(int32(v.Int()))
+	case reflect.Int16:
+		e.EncodeInt16(int16(v.Int()))
+	case reflect.Int8:
+		e.EncodeInt8(int8(v.Int()))
+	case reflect.Uint:
+		e.EncodeUint(uint(v.Uint()))
+	case reflect.Uint64:
+		e.EncodeUint64(v.Uint())
+	case reflect.Uint32:
+		e.EncodeUint32(uint32(v.Uint()))
+	case reflect.Uint16:
+		e.EncodeUint16(uint16(v.Uint()))
+	case reflect.Uint8:
+		e.EncodeUint8(uint8(v.Uint()))
+	case reflect.Float64:
+		e.EncodeFloat64(v.Float())
+	case reflect.Float32:
+		e.EncodeFloat32(float32(v.Float()))
+	case reflect.Complex128:
+		e.EncodeComplex128(v.Complex())
+	case reflect.Complex64:
+		e.EncodeComplex64(complex64(v.Complex()))
+	case reflect.String:
+		return e.encodeString(v.String())
+	}
+	return nil
+}
+
+// decodeValue decodes a new value of type t, which must be a type that
+// supportedType accepts.
+//
+// If ref isn't negative, then a new Codable pointer is stored as the reference
+// defined at offset ref before its value is decoded so that values that refer
+// back to it decode.
+func (d *Decoder) decodeValue(t reflect.Type, ref int) (reflect.Value, error) {
+	if t.Kind() == reflect.Ptr && t.Implements(codableType) {
+		p := reflect.New(t.Elem())
+		if ref >= 0 {
+			// A section decoding in parallel may have stored the pointer first
+			if v, ok := d.refs.store(ref, p.Interface()); ok {
+				return reflect.ValueOf(v), nil
+			}
+		}
+
+		if err := p.Interface().(Codable).DecodeFrom(d); err != nil {
+			return reflect.Value{}, err
+		}
+		return p, nil
+	}
+
+	if reflect.PtrTo(t).Implements(codableType) {
+		p := reflect.New(t)
+		if err := p.Interface().(Codable).DecodeFrom(d); err != nil {
+			return reflect.Value{}, err
+		}
+		return p.Elem(), nil
+	}
+
+	var x interface{}
+	var err error
+
+	if nativeTypes[t] {
+		switch reflect.Zero(t).Interface().(type) {
+		case time.Time:
+			x, err = d.DecodeTime()
+		case time.Duration:
+			x, err = d.DecodeDuration()
+		case Decimal:
+			x, err = d.DecodeDecimal()
+		case Int128:
+			x, err = d.DecodeInt128()
+		case Uint128:
+			x, err = d.DecodeUint128()
+		case UUID:
+			x, err = d.DecodeUUID()
+		case netip.Addr:
+			x, err = d.DecodeAddr()
+		case netip.Prefix:
+			x, err = d.DecodePrefix()
+		case netip.AddrPort:
+			x, err = d.DecodeAddrPort()
+		case *url.URL:
+			x, err = d.DecodeURL()
+		case *big.Int:
+			x, err = d.DecodeBigInt()
+		case *big.Rat:
+			x, err = d.DecodeBigRat()
+		case *big.Float:
+			x, err = d.DecodeBigFloat()
+		case []byte:
+			x, err = d.DecodeNullableData()
+		case *Tensor:
+			x, err = d.DecodeTensor()
+		case *Bitset:
+			x, err = d.DecodeBitset()
+		case *SparseVector:
+			x, err = d.DecodeSparseVector()
+		}
+	} else {
+		switch t.Kind() {
+		case reflect.Array:
+			p := reflect.New(t)
+			if err = d.DecodeArray(p.Interface()); err == nil {
+				x = p.Elem().Interface()
+			}
+		case reflect.Bool:
+			x, err = d.DecodeBool()
+		case reflect.Int:
+			x, err = d.DecodeInt()
+		case reflect.Int64:
+			x, err = d.DecodeInt64()
+		case reflect.Int32:
+			x, err = d.DecodeInt32()
+		case reflect.Int16:
+			x, err = d.DecodeInt16()
+		case reflect.Int8:
+			x, err = d.DecodeInt8()
+		case reflect.Uint:
+			x, err = d.DecodeUint()
+		case reflect.Uint64:
+			x, err = d.DecodeUint64()
+		case reflect.Uint32:
+			x, err = d.DecodeUint32()
+		case reflect.Uint16:
+			x, err = d.DecodeUint16()
+		case reflect.Uint8:
+			x, err = d.DecodeUint8()
+		case reflect.Float64:
+			x, err = d.DecodeFloat64()
+		case reflect.Float32:
+			x, err = d.DecodeFloat32()
+		case reflect.Complex128:
+			x, err = d.DecodeComplex128()
+		case reflect.Complex64:
+			x, err = d.DecodeComplex64()
+		case reflect.String:
+			x, err = d.DecodeString()
+		}
+	}
+
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(x).Convert(t), nil
+}
@@ -0,0 +1,80 @@
+package coding
+
+import (
+	"math"
+	"reflect"
+	"sort"
+)
+
+// setKind returns the element kind of the set elements s, which is the coding
+// type of a fixed-width numeric type or of strings.
+//
+// If s isn't a slice of a fixed-width numeric type or a []string, then false is
+// returned.
+func setKind(s interface{}) (byte, bool) {
+	if _, ok := s.([]string); ok {
+		return codingTypeString, true
+	}
+
+	kind, _, ok := elementKind(s)
+	return kind, ok
+}
+
+// sortedSet returns a sorted copy of the set elements s without duplicates.
+//
+// Negative zeros are replaced with zeros, and ErrValue is returned if s
+// contains NaN, which can't be ordered.
+func sortedSet(s interface{}) (interface{}, error) {
+	v := reflect.ValueOf(s)
+	c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	reflect.Copy(c, v)
+
+	if k := c.Type().Elem().Kind(); k == reflect.Float64 || k == reflect.Float32 {
+		for i := 0; i < c.Len(); i++ {
+			f := c.Index(i).Float()
+			if math.IsNaN(f) {
+				return nil, ErrValue
+			} else if f == 0 {
+				c.Index(i).SetFloat(0)
+			}
+		}
+	}
+
+	sort.Slice(c.Interface(), func(i, j int) bool {
+		return setLess(c.Index(i), c.Index(j))
+	})
+
+	n := 0
+	for i := 0; i < c.Len(); i++ {
+		if n == 0 || setLess(c.Index(n-1), c.Index(i)) {
+			c.Index(n).Set(c.Index(i))
+			n++
+		}
+	}
+	return c.Slice(0, n).Interface(), nil
+}
+
+// isSortedSet returns whether or not the elements of s are strictly increasing.
+func isSortedSet(s interface{}) bool {
+	v := reflect.ValueOf(s)
+	for i := 1; i < v.Len(); i++ {
+		if !setLess(v.Index(i-1), v.Index(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// setLess returns whether or not the set element a is less than b.
+func setLess(a reflect.Value, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		return a.Int() < b.Int()
+	case reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		return a.Uint() < b.Uint()
+	case reflect.Float64, reflect.Float32:
+		return a.Float() < b.Float()
+	default:
+		return a.String() < b.String()
+	}
+}
@@ -0,0 +1,133 @@
+package coding
+
+// SparseVector types are vectors that only store their nonzero elements.
+//
+// A sparse vector's values are a []float32 or a []float64 holding the elements
+// at its indices, which are sorted in ascending order.
+type SparseVector struct {
+
+	// The number of elements in the vector.
+	length int
+
+	// The indices of the vector's stored elements in ascending order.
+	indices []int
+
+	// The vector's stored elements.
+	values interface{}
+}
+
+// Initializers
+
+// NewSparseVector creates a new sparse vector with the given length, indices
+// and values.
+//
+// The indices must be strictly increasing and less than length, and values
+// must be a []float32 or a []float64 with the same length as indices.
+func NewSparseVector(length int, indices []int, values interface{}) (*SparseVector, error) {
+	kind, n, ok := elementKind(values)
+	if !ok || (kind != codingTypeFloat32 && kind != codingTypeFloat64) {
+		return nil, ErrSparseVector
+	}
+
+	if length < 0 || n != len(indices) {
+		return nil, ErrSparseVector
+	}
+
+	for i, j := range indices {
+		if j < 0 || j >= length || (i > 0 && j <= indices[i-1]) {
+			return nil, ErrSparseVector
+		}
+	}
+
+	return &SparseVector{
+		length:  length,
+		indices: append([]int{}, indices...),
+		values:  values,
+	}, nil
+}
+
+// NewSparseVectorFromFloat32s creates a new sparse vector holding the nonzero
+// elements of dense.
+func NewSparseVectorFromFloat32s(dense []float32) *SparseVector {
+	v := &SparseVector{length: len(dense)}
+
+	var values []float32
+	for i, f := range dense {
+		if f != 0 {
+			v.indices = append(v.indices, i)
+			values = append(values, f)
+		}
+	}
+
+	v.values = values
+	return v
+}
+
+// NewSparseVectorFromFloat64s creates a new sparse vector holding the nonzero
+// elements of dense.
+func NewSparseVectorFromFloat64s(dense []float64) *SparseVector {
+	v := &SparseVector{length: len(dense)}
+
+	var values []float64
+	for i, f := range dense {
+		if f != 0 {
+			v.indices = append(v.indices, i)
+			values = append(values, f)
+		}
+	}
+
+	v.values = values
+	return v
+}
+
+// Exported methods
+
+// Len returns the number of elements in the vector.
+func (v *SparseVector) Len() int {
+	return v.length
+}
+
+// Indices returns the indices of the vector's stored elements in ascending
+// order.
+func (v *SparseVector) Indices() []int {
+	return append([]int{}, v.indices...)
+}
+
+// Values returns the vector's stored elements as a []float32 or a []float64.
+//
+// The returned slice shares memory with the vector.
+func (v *SparseVector) Values() interface{} {
+	return v.values
+}
+
+// Float32s returns the vector as a dense slice.
+func (v *SparseVector) Float32s() []float32 {
+	dense := make([]float32, v.length)
+	switch values := v.values.(type) {
+	case []float32:
+		for i, j := range v.indices {
+			dense[j] = values[i]
+		}
+	case []float64:
+		for i, j := range v.indices {
+			dense[j] = float32(values[i])
+		}
+	}
+	return dense
+}
+
+// Float64s returns the vector as a dense slice.
+func (v *SparseVector) Float64s() []float64 {
+	dense := make([]float64, v.length)
+	switch values := v.values.(type) {
+	case []float32:
+		for i, j := range v.indices {
+			dense[j] = float64(values[i])
+		}
+	case []float64:
+		for i, j := range v.indices {
+			dense[j] = values[i]
+		}
+	}
+	return dense
+}
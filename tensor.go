@@ -0,0 +1,76 @@
+package coding
+
+// Tensor types are n-dimensional arrays of numbers.
+//
+// A tensor's data is a flat slice of a fixed-width numeric type, such as
+// []float64 or []int32, laid out in row-major order.
+type Tensor struct {
+
+	// The length of each of the tensor's dimensions.
+	shape []int
+
+	// The tensor's elements in row-major order.
+	data interface{}
+}
+
+// Initializers
+
+// NewTensor creates a new tensor with the given shape and data.
+//
+// The data must be a slice of a fixed-width numeric type whose length is the
+// product of the lengths in shape. A tensor with an empty shape is a scalar
+// with exactly one element.
+func NewTensor(shape []int, data interface{}) (*Tensor, error) {
+	_, n, ok := elementKind(data)
+	if !ok {
+		return nil, ErrTensor
+	}
+
+	if l, ok := shapeLength(shape); !ok || l != n {
+		return nil, ErrTensor
+	}
+
+	return &Tensor{
+		shape: append([]int{}, shape...),
+		data:  data,
+	}, nil
+}
+
+// Exported methods
+
+// Shape returns the length of each of the tensor's dimensions.
+func (t *Tensor) Shape() []int {
+	return append([]int{}, t.shape...)
+}
+
+// Data returns the tensor's elements in row-major order.
+//
+// The returned value is a slice of the tensor's element type, such as
+// []float64, and shares memory with the tensor.
+func (t *Tensor) Data() interface{} {
+	return t.data
+}
+
+// Non-exported functions
+
+// shapeLength returns the number of elements in a tensor with the given
+// shape.
+//
+// If the shape has a negative dimension, or its number of elements overflows
+// an int, then false is returned.
+func shapeLength(shape []int) (int, bool) {
+	const maxLength = int(^uint(0) >> 1)
+
+	l := 1
+	for _, s := range shape {
+		if s < 0 {
+			return 0, false
+		}
+
+		if s > 0 && l > maxLength/s {
+			return 0, false
+		}
+		l *= s
+	}
+	return l, true
+}
@@ -0,0 +1,769 @@
+package coding
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"math/big"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// elementNames are the JSON type names of the packed element kinds.
+var elementNames = map[byte]string{
+	codingTypeFloat64: "float64",
+	codingTypeFloat32: "float32",
+	codingTypeInt64:   "int64",
+	codingTypeInt32:   "int32",
+	codingTypeInt16:   "int16",
+	codingTypeInt8:    "int8",
+	codingTypeUint64:  "uint64",
+	codingTypeUint32:  "uint32",
+	codingTypeUint16:  "uint16",
+	codingTypeUint8:   "uint8",
+}
+
+// Exported functions
+
+// ToJSON converts encoded data, such as the data returned by an encoder's Data
+// function, to JSON.
+//
+// The JSON is an array of the data's values. Nil values are written as null,
+// and every other value is written as an object whose only key is the value's
+// type, such as {"int8":-3} or {"[]float32":[1.5,2]}, so that FromJSON can
+// convert the JSON back to the same values. Byte slices are written as base64
+// strings, and floats that aren't numbers as "NaN", "+Inf" or "-Inf". A
+// reference to a shared value is written as {"anyref":n}, where n is the index
+// of the value's definition among the JSON's "anydef" values, so references
+// don't depend on the sizes of the values before them. If the data is indexed,
+// then the array is wrapped as {"indexed":[...]}.
+//
+// Strings that aren't valid UTF-8 can't be written as JSON, so ErrUTF8 is
+// returned if the data contains one.
+func ToJSON(data []byte) ([]byte, error) {
+	d := NewDecoder(data)
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	d.jsonDefs = make(map[int]int)
+
+	indexed, err := d.Indexed()
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	if indexed {
+		b = append(b, `{"indexed":`...)
+	}
+
+	if b, err = d.appendJSONValues(b); err != nil {
+		return nil, err
+	}
+
+	if indexed {
+		b = append(b, '}')
+	}
+	return b, nil
+}
+
+// Non-exported methods
+
+// appendJSONValues appends a JSON array of the decoder's remaining values.
+func (d *Decoder) appendJSONValues(b []byte) ([]byte, error) {
+	b = append(b, '[')
+	for i := 0; d.offset < d.end; i++ {
+		if i > 0 {
+			b = append(b, ',')
+		}
+
+		var err error
+		if b, err = d.appendJSON(b); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, ']'), nil
+}
+
+// appendJSON decodes the next value and appends it as annotated JSON.
+func (d *Decoder) appendJSON(b []byte) ([]byte, error) {
+	t, err := d.peekType()
+	if err != nil {
+		return nil, err
+	}
+
+	if t == codingTypeNil {
+		_, err := d.DecodeNil()
+		return append(b, "null"...), err
+	}
+
+	switch t {
+	case codingTypeBool:
+		var v bool
+		if v, err = d.DecodeBool(); err == nil {
+			b = strconv.AppendBool(appendJSONName(b, "bool"), v)
+		}
+	case codingTypeInt:
+		var n int
+		if n, err = d.DecodeInt(); err == nil {
+			b = strconv.AppendInt(appendJSONName(b, "int"), int64(n), 10)
+		}
+	case codingTypeInt64:
+		var n int64
+		if n, err = d.DecodeInt64(); err == nil {
+			b = strconv.AppendInt(appendJSONName(b, "int64"), n, 10)
+		}
+	case codingTypeInt32:
+		var n int32
+		if n, err = d.DecodeInt32(); err == nil {
+			b = strconv.AppendInt(appendJSONName(b, "int32"), int64(n), 10)
+		}
+	case codingTypeInt16:
+		var n int16
+		if n, err = d.DecodeInt16(); err == nil {
+			b = strconv.AppendInt(appendJSONName(b, "int16"), int64(n), 10)
+		}
+	case codingTypeInt8:
+		var n int8
+		if n, err = d.DecodeInt8(); err == nil {
+			b = strconv.AppendInt(appendJSONName(b, "int8"), int64(n), 10)
+		}
+	case codingTypeUint:
+		var n uint
+		if n, err = d.DecodeUint(); err == nil {
+			b = strconv.AppendUint(appendJSONName(b, "uint"), uint64(n), 10)
+		}
+	case codingTypeUint64:
+		var n uint64
+		if n, err = d.DecodeUint64(); err == nil {
+			b = strconv.AppendUint(appendJSONName(b, "uint64"), n, 10)
+		}
+	case codingTypeUint32:
+		var n uint32
+		if n, err = d.DecodeUint32(); err == nil {
+			b = strconv.AppendUint(appendJSONName(b, "uint32"), uint64(n), 10)
+		}
+	case codingTypeUint16:
+		var n uint16
+		if n, err = d.DecodeUint16(); err == nil {
+			b = strconv.AppendUint(appendJSONName(b, "uint16"), uint64(n), 10)
+		}
+	case codingTypeUint8:
+		var n uint8
+		if n, err = d.DecodeUint8(); err == nil {
+			b = strconv.AppendUint(appendJSONName(b, "uint8"), uint64(n), 10)
+		}
+	case codingTypeFloat64:
+		var f float64
+		if f, err = d.DecodeFloat64(); err == nil {
+			b = appendJSONFloat(appendJSONName(b, "float64"), f, 64)
+		}
+	case codingTypeFloat32:
+		var f float32
+		if f, err = d.DecodeFloat32(); err == nil {
+			b = appendJSONFloat(appendJSONName(b, "float32"), float64(f), 32)
+		}
+	case codingTypeFloat16:
+		var f float32
+		if f, err = d.DecodeFloat16(); err == nil {
+			b = appendJSONFloat(appendJSONName(b, "float16"), float64(f), 32)
+		}
+	case codingTypeBFloat16:
+		var f float32
+		if f, err = d.DecodeBFloat16(); err == nil {
+			b = appendJSONFloat(appendJSONName(b, "bfloat16"), float64(f), 32)
+		}
+	case codingTypeComplex128:
+		var c complex128
+		if c, err = d.DecodeComplex128(); err == nil {
+			b = appendJSONFloats(appendJSONName(b, "complex128"), []float64{real(c), imag(c)}, 64)
+		}
+	case codingTypeComplex64:
+		var c complex64
+		if c, err = d.DecodeComplex64(); err == nil {
+			b = appendJSONFloats(appendJSONName(b, "complex64"), []float64{float64(real(c)), float64(imag(c))}, 32)
+		}
+	case codingTypeInt128:
+		var n Int128
+		if n, err = d.DecodeInt128(); err == nil {
+			b = appendJSONQuoted(appendJSONName(b, "int128"), int128ToBig(n).String())
+		}
+	case codingTypeUint128:
+		var n Uint128
+		if n, err = d.DecodeUint128(); err == nil {
+			b = appendJSONQuoted(appendJSONName(b, "uint128"), uint128ToBig(n).String())
+		}
+	case codingTypeString, codingTypeStringDef, codingTypeStringRef:
+		var s string
+		if s, err = d.DecodeString(); err == nil {
+			b, err = appendJSONString(appendJSONName(b, "string"), s)
+		}
+	case codingTypeData:
+		var v []byte
+		if v, err = d.DecodeData(); err == nil {
+			b = appendJSONBytes(appendJSONName(b, "bytes"), v)
+		}
+	case codingTypeSlice, codingTypeRunLengthSlice:
+		if !d.checkLength(2) {
+			return nil, ErrEOB
+		}
+
+		kind := d.data[d.offset+1]
+		var s interface{}
+		if s, err = d.decodeSlice(kind); err == nil {
+			b = appendJSONElements(appendJSONName(b, "[]"+elementNames[kind]), kind, s)
+		}
+	case codingTypeFloat16Slice:
+		var fs []float32
+		if fs, err = d.DecodeFloat16s(); err == nil {
+			b = appendJSONElements(appendJSONName(b, "[]float16"), codingTypeFloat32, fs)
+		}
+	case codingTypeBFloat16Slice:
+		var fs []float32
+		if fs, err = d.DecodeBFloat16s(); err == nil {
+			b = appendJSONElements(appendJSONName(b, "[]bfloat16"), codingTypeFloat32, fs)
+		}
+	case codingTypeArray:
+		b, err = d.appendJSONArray(b)
+	case codingTypeBitset:
+		var bs *Bitset
+		if bs, err = d.DecodeBitset(); err == nil {
+			bits := make([]byte, bs.Len())
+			for i := range bits {
+				bits[i] = '0'
+				if bs.Test(i) {
+					bits[i] = '1'
+				}
+			}
+			b = appendJSONQuoted(appendJSONName(b, "bitset"), string(bits))
+		}
+	case codingTypeTensor:
+		var x *Tensor
+		if x, err = d.DecodeTensor(); err == nil {
+			kind, _, _ := elementKind(x.data)
+			b = appendJSONInts(append(appendJSONName(b, "tensor"), `{"shape":`...), x.shape)
+			b = appendJSONElements(append(b, `,"data":{"[]`+elementNames[kind]+`":`...), kind, x.data)
+			b = append(b, "}}"...)
+		}
+	case codingTypeSparseVector:
+		var v *SparseVector
+		if v, err = d.DecodeSparseVector(); err == nil {
+			kind, _, _ := elementKind(v.values)
+			b = strconv.AppendInt(append(appendJSONName(b, "sparsevector"), `{"length":`...), int64(v.length), 10)
+			b = appendJSONInts(append(b, `,"indices":`...), v.indices)
+			b = appendJSONElements(append(b, `,"values":{"[]`+elementNames[kind]+`":`...), kind, v.values)
+			b = append(b, "}}"...)
+		}
+	case codingTypeInt64Series:
+		var s []int64
+		if s, err = d.DecodeInt64Series(); err == nil {
+			b = appendJSONElements(appendJSONName(b, "int64series"), codingTypeInt64, s)
+		}
+	case codingTypeFloat64Series:
+		var s []float64
+		if s, err = d.DecodeFloat64Series(); err == nil {
+			b = appendJSONFloats(appendJSONName(b, "float64series"), s, 64)
+		}
+	case codingTypeBigInt:
+		var x *big.Int
+		if x, err = d.DecodeBigInt(); err == nil {
+			b = appendJSONQuoted(appendJSONName(b, "bigint"), x.String())
+		}
+	case codingTypeBigRat:
+		var x *big.Rat
+		if x, err = d.DecodeBigRat(); err == nil {
+			b = appendJSONQuoted(appendJSONName(b, "bigrat"), x.String())
+		}
+	case codingTypeBigFloat:
+		var x *big.Float
+		if x, err = d.DecodeBigFloat(); err == nil {
+			b = appendJSONQuoted(append(appendJSONName(b, "bigfloat"), `{"value":`...), x.Text('p', 0))
+			b = strconv.AppendUint(append(b, `,"prec":`...), uint64(x.Prec()), 10)
+			b = appendJSONQuoted(append(b, `,"mode":`...), x.Mode().String())
+			b = append(b, '}')
+		}
+	case codingTypeDecimal:
+		var x Decimal
+		if x, err = d.DecodeDecimal(); err == nil {
+			s := x.String()
+			if x.scale < 0 {
+				s = strconv.FormatInt(x.unscaled, 10) + "e" + strconv.Itoa(-int(x.scale))
+			}
+			b = appendJSONQuoted(appendJSONName(b, "decimal"), s)
+		}
+	case codingTypeTime:
+		var x time.Time
+		if x, err = d.DecodeTime(); err == nil {
+			b, err = appendJSONTime(appendJSONName(b, "time"), x)
+		}
+	case codingTypeDuration:
+		var x time.Duration
+		if x, err = d.DecodeDuration(); err == nil {
+			b = appendJSONQuoted(appendJSONName(b, "duration"), x.String())
+		}
+	case codingTypeUUID:
+		var u UUID
+		if u, err = d.DecodeUUID(); err == nil {
+			b = appendJSONQuoted(appendJSONName(b, "uuid"), u.String())
+		}
+	case codingTypeAddr:
+		var a netip.Addr
+		if a, err = d.DecodeAddr(); err == nil {
+			b, err = appendJSONString(appendJSONName(b, "addr"), formatJSONAddr(a))
+		}
+	case codingTypePrefix:
+		var p netip.Prefix
+		if p, err = d.DecodePrefix(); err == nil {
+			s := ""
+			if p.IsValid() {
+				s = p.String()
+			}
+			b, err = appendJSONString(appendJSONName(b, "prefix"), s)
+		}
+	case codingTypeAddrPort:
+		var ap netip.AddrPort
+		if ap, err = d.DecodeAddrPort(); err == nil {
+			s := ""
+			if ap.IsValid() {
+				s = ap.String()
+			}
+			b, err = appendJSONString(appendJSONName(b, "addrport"), s)
+		}
+	case codingTypeURL:
+		var u *url.URL
+		if u, err = d.DecodeURL(); err == nil {
+			b, err = appendJSONString(appendJSONName(b, "url"), u.String())
+		}
+	case codingTypeEnum:
+		b, err = d.appendJSONEnum(b)
+	case codingTypeSet:
+		var s interface{}
+		if s, err = d.DecodeSet(); err == nil {
+			b = appendJSONName(b, "set")
+			if ss, ok := s.([]string); ok {
+				b = append(appendJSONName(b, "[]string"), '[')
+				for i, x := range ss {
+					if i > 0 {
+						b = append(b, ',')
+					}
+
+					if b, err = appendJSONString(b, x); err != nil {
+						return nil, err
+					}
+				}
+				b = append(b, ']')
+			} else {
+				kind, _, _ := elementKind(s)
+				b = appendJSONElements(appendJSONName(b, "[]"+elementNames[kind]), kind, s)
+			}
+			b = append(b, '}')
+		}
+	case codingTypeMap:
+		b, err = d.appendJSONMap(b)
+	case codingTypeSection:
+		var sd *Decoder
+		if sd, err = d.EnterSection(); err == nil {
+			b, err = sd.appendJSONValues(appendJSONName(b, "section"))
+		}
+	case codingTypeRecord:
+		b, err = d.appendJSONRecord(b)
+	case codingTypeAny, codingTypeAnyDef:
+		b, err = d.appendJSONAny(b, t)
+	case codingTypeAnyRef:
+		r := d.offset
+		d.incrementOffset(1)
+
+		var n uint64
+		if n, err = d.decodeUvarint(); err == nil {
+			// The definition must come before the reference, so it has
+			// already been written
+			i, ok := d.jsonDefs[r-int(n)]
+			if n == 0 || n > uint64(r) || !ok {
+				return nil, ErrReference
+			}
+			b = strconv.AppendInt(appendJSONName(b, "anyref"), int64(i), 10)
+		}
+	default:
+		return nil, ErrType
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '}'), nil
+}
+
+// appendJSONArray decodes the next value, which must be a fixed-size array,
+// and appends it as annotated JSON without a closing brace.
+func (d *Decoder) appendJSONArray(b []byte) ([]byte, error) {
+	if err := d.checkType(codingTypeArray); err != nil {
+		return nil, err
+	}
+
+	if !d.checkLength(1) {
+		return nil, ErrEOB
+	}
+
+	kind := d.getByte()
+	size := elementSize(kind)
+	if size == 0 {
+		return nil, ErrValue
+	}
+
+	n, err := d.getElementCount(size)
+	if err != nil {
+		return nil, err
+	}
+
+	s := makeElements(kind, n)
+	unpackElements(s, d.getBytes(n*size))
+
+	name := "[" + strconv.Itoa(n) + "]" + elementNames[kind]
+	return appendJSONElements(appendJSONName(b, name), kind, s), nil
+}
+
+// appendJSONEnum decodes the next value, which must be an enum, and appends
+// its ordinal or name as annotated JSON without a closing brace.
+//
+// Enums are appended without their registered types, so their ordinals and
+// names aren't checked.
+func (d *Decoder) appendJSONEnum(b []byte) ([]byte, error) {
+	if err := d.checkType(codingTypeEnum); err != nil {
+		return nil, err
+	}
+
+	if !d.checkLength(1) {
+		return nil, ErrEOB
+	}
+
+	b = appendJSONName(b, "enum")
+	switch d.getByte() {
+	case enumOrdinal:
+		n, err := d.decodeVarint()
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendInt(b, n, 10), nil
+	case enumName:
+		s, err := d.getString()
+		if err != nil {
+			return nil, err
+		}
+		return appendJSONString(b, s)
+	default:
+		return nil, ErrValue
+	}
+}
+
+// appendJSONMap decodes the next value, which must be a map, and appends it as
+// annotated JSON without a closing brace.
+//
+// The map's entries are appended as an array of key and value pairs so that
+// their order is kept.
+func (d *Decoder) appendJSONMap(b []byte) ([]byte, error) {
+	if err := d.checkType(codingTypeMap); err != nil {
+		return nil, err
+	}
+
+	if !d.checkLength(1) {
+		return nil, ErrEOB
+	}
+
+	switch d.getByte() {
+	case mapInsertionOrder:
+		b = appendJSONName(b, "map")
+	case mapSorted:
+		b = appendJSONName(b, "sortedmap")
+	default:
+		return nil, ErrValue
+	}
+
+	n, err := d.getElementCount(2)
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(b, '[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b = append(b, ',')
+		}
+
+		k, err := d.getString()
+		if err != nil {
+			return nil, err
+		}
+
+		if b, err = appendJSONString(append(b, '['), k); err != nil {
+			return nil, err
+		}
+
+		if b, err = d.appendJSON(append(b, ',')); err != nil {
+			return nil, err
+		}
+		b = append(b, ']')
+	}
+	return append(b, ']'), nil
+}
+
+// appendJSONRecord decodes the next value, which must be a record, and appends
+// it as annotated JSON without a closing brace.
+//
+// The record's fields are appended in the order that they were encoded as an
+// array of field number and value array pairs, so that fields with the same
+// number and references between fields are kept.
+func (d *Decoder) appendJSONRecord(b []byte) ([]byte, error) {
+	start, end, err := d.decodeBlock(codingTypeRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	b = append(appendJSONName(b, "record"), '[')
+	rd := d.subDecoder(start, end)
+	for i := 0; rd.offset < rd.end; i++ {
+		if i > 0 {
+			b = append(b, ',')
+		}
+
+		n, err := rd.decodeUvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		if n > math.MaxInt32 {
+			return nil, ErrFieldNumber
+		}
+
+		l, err := rd.decodeInt64(8)
+		if err != nil {
+			return nil, err
+		}
+
+		if l < 0 || !rd.checkLength(int(l)) {
+			return nil, ErrEOB
+		}
+
+		fd := rd.subDecoder(rd.offset, rd.offset+int(l))
+		rd.incrementOffset(int(l))
+
+		b = append(strconv.AppendUint(append(b, '['), n, 10), ',')
+		if b, err = fd.appendJSONValues(b); err != nil {
+			return nil, err
+		}
+		b = append(b, ']')
+	}
+	return append(b, ']'), nil
+}
+
+// appendJSONAny decodes the next value, which must be a registered value of
+// type t, and appends it as annotated JSON without a closing brace.
+//
+// The value's type doesn't need to be registered. Its type ID and the values
+// that it was encoded with are appended, along with its type's name if it is
+// registered.
+func (d *Decoder) appendJSONAny(b []byte, t byte) ([]byte, error) {
+	o := d.offset
+	start, end, err := d.decodeBlock(t)
+	if err != nil {
+		return nil, err
+	}
+
+	// Number the definition before its values are appended so that values
+	// that refer back to it can be written
+	if t == codingTypeAnyDef {
+		d.jsonDefs[o] = len(d.jsonDefs)
+	}
+
+	ad := d.subDecoder(start, end)
+	if !ad.checkLength(4) {
+		return nil, ErrEOB
+	}
+
+	id := binary.LittleEndian.Uint32(ad.getBytes(4))
+	if t == codingTypeAnyDef {
+		b = appendJSONName(b, "anydef")
+	} else {
+		b = appendJSONName(b, "any")
+	}
+
+	b = strconv.AppendUint(append(b, `{"id":`...), uint64(id), 10)
+	if r, ok := lookupID(id); ok {
+		if b, err = appendJSONString(append(b, `,"type":`...), r.name); err != nil {
+			return nil, err
+		}
+	}
+
+	if b, err = ad.appendJSONValues(append(b, `,"values":`...)); err != nil {
+		return nil, err
+	}
+	return append(b, '}'), nil
+}
+
+// peekType returns the type of the next value without changing the decoder's
+// offset.
+func (d *Decoder) peekType() (byte, error) {
+	if err := d.load(); err != nil {
+		return 0, err
+	}
+
+	if !d.checkLength(1) {
+		return 0, ErrEOB
+	}
+	return d.data[d.offset], nil
+}
+
+// Non-exported functions
+
+// appendJSONName appends the opening brace and key of an annotated value.
+func appendJSONName(b []byte, name string) []byte {
+	b = append(b, `{"`...)
+	b = append(b, name...)
+	return append(b, `":`...)
+}
+
+// appendJSONString appends s as a JSON string.
+//
+// ErrUTF8 is returned if s isn't valid UTF-8.
+func appendJSONString(b []byte, s string) ([]byte, error) {
+	if !utf8.ValidString(s) {
+		return nil, ErrUTF8
+	}
+
+	// Marshaling a valid string never fails
+	j, _ := json.Marshal(s)
+	return append(b, j...), nil
+}
+
+// appendJSONQuoted appends s, which must only contain characters that don't
+// need escaping, as a JSON string.
+func appendJSONQuoted(b []byte, s string) []byte {
+	b = append(b, '"')
+	b = append(b, s...)
+	return append(b, '"')
+}
+
+// appendJSONBytes appends v as a base64 JSON string.
+func appendJSONBytes(b []byte, v []byte) []byte {
+	return appendJSONQuoted(b, base64.StdEncoding.EncodeToString(v))
+}
+
+// appendJSONFloat appends f as a JSON number, or as a JSON string if it is NaN
+// or infinite.
+func appendJSONFloat(b []byte, f float64, bitSize int) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(b, `"NaN"`...)
+	case math.IsInf(f, 1):
+		return append(b, `"+Inf"`...)
+	case math.IsInf(f, -1):
+		return append(b, `"-Inf"`...)
+	default:
+		return strconv.AppendFloat(b, f, 'g', -1, bitSize)
+	}
+}
+
+// appendJSONFloats appends fs as a JSON array of numbers.
+func appendJSONFloats(b []byte, fs []float64, bitSize int) []byte {
+	b = append(b, '[')
+	for i, f := range fs {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendJSONFloat(b, f, bitSize)
+	}
+	return append(b, ']')
+}
+
+// appendJSONInts appends ns as a JSON array of numbers.
+func appendJSONInts(b []byte, ns []int) []byte {
+	b = append(b, '[')
+	for i, n := range ns {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendInt(b, int64(n), 10)
+	}
+	return append(b, ']')
+}
+
+// appendJSONElements appends the numeric slice s of the given kind as a JSON
+// array of numbers, or as a base64 JSON string if its elements are bytes.
+func appendJSONElements(b []byte, kind byte, s interface{}) []byte {
+	if kind == codingTypeUint8 {
+		return appendJSONBytes(b, s.([]uint8))
+	}
+
+	v := reflect.ValueOf(s)
+	b = append(b, '[')
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			b = append(b, ',')
+		}
+
+		switch x := v.Index(i); {
+		case x.CanInt():
+			b = strconv.AppendInt(b, x.Int(), 10)
+		case x.CanUint():
+			b = strconv.AppendUint(b, x.Uint(), 10)
+		default:
+			b = appendJSONFloat(b, x.Float(), 8*elementSize(kind))
+		}
+	}
+	return append(b, ']')
+}
+
+// appendJSONTime appends t as a JSON object of its Unix time in seconds and
+// nanoseconds, followed by its zone offset and its zone's name or location
+// unless t is a UTC time, so that every time that can be encoded can be
+// written.
+func appendJSONTime(b []byte, t time.Time) ([]byte, error) {
+	b = strconv.AppendInt(append(b, `{"sec":`...), t.Unix(), 10)
+	b = strconv.AppendInt(append(b, `,"nsec":`...), int64(t.Nanosecond()), 10)
+	if t.Location() == time.UTC {
+		return append(b, '}'), nil
+	}
+
+	// Zones are written as they are by EncodeTime
+	name, offset := t.Zone()
+	b = strconv.AppendInt(append(b, `,"offset":`...), int64(offset), 10)
+	if l := t.Location().String(); l != "" && l != "Local" && l != name {
+		b = append(b, `,"location":`...)
+		name = l
+	} else {
+		b = append(b, `,"zone":`...)
+	}
+
+	b, err := appendJSONString(b, name)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '}'), nil
+}
+
+// formatJSONAddr formats a, or returns an empty string if a is the zero
+// address.
+func formatJSONAddr(a netip.Addr) string {
+	if !a.IsValid() {
+		return ""
+	}
+	return a.String()
+}
+
+// int128ToBig returns n as an arbitrary precision integer.
+func int128ToBig(n Int128) *big.Int {
+	x := big.NewInt(n.Hi)
+	x.Lsh(x, 64)
+	return x.Add(x, new(big.Int).SetUint64(n.Lo))
+}
+
+// uint128ToBig returns n as an arbitrary precision integer.
+func uint128ToBig(n Uint128) *big.Int {
+	x := new(big.Int).SetUint64(n.Hi)
+	x.Lsh(x, 64)
+	return x.Add(x, new(big.Int).SetUint64(n.Lo))
+}
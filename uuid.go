@@ -0,0 +1,40 @@
+package coding
+
+import "encoding/hex"
+
+// UUID types are 16-byte universally unique identifiers.
+type UUID [16]byte
+
+// Initializers
+
+// ParseUUID parses a UUID from its canonical form, such as
+// "123e4567-e89b-12d3-a456-426614174000".
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, ErrUUID
+	}
+
+	b := []byte(s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36])
+	if _, err := hex.Decode(u[:], b); err != nil {
+		return UUID{}, ErrUUID
+	}
+	return u, nil
+}
+
+// Exported methods
+
+// String returns the UUID in its canonical form.
+func (u UUID) String() string {
+	b := make([]byte, 36)
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b)
+}